@@ -0,0 +1,103 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+)
+
+// Activation names the function a node applies to the sum of its inputs
+type Activation string
+
+const (
+	Sigmoid  Activation = "sigmoid" // Steepened sigmoid, 1/(1+e^-4.9x), as in NEAT
+	Tanh     Activation = "tanh"
+	ReLU     Activation = "relu"
+	Gaussian Activation = "gaussian" // e^-x²
+	Sine     Activation = "sine"
+	Abs      Activation = "abs"
+	Step     Activation = "step" // 1 if x > 0, otherwise 0
+	Identity Activation = "identity"
+)
+
+// Returns true if the activation is one of the known functions. The empty
+// activation is the default, sigmoid.
+func (a Activation) Known() bool {
+	switch a {
+	case "", Sigmoid, Tanh, ReLU, Gaussian, Sine, Abs, Step, Identity:
+		return true
+	}
+	return false
+}
+
+// Applies the function to x. The empty or an unknown activation is sigmoid.
+func (a Activation) Apply(x float64) float64 {
+	return a.Func()(x)
+}
+
+// Returns the function itself, for callers applying it many times. The empty
+// or an unknown activation is sigmoid.
+func (a Activation) Func() func(x float64) float64 {
+	switch a {
+	case Tanh:
+		return math.Tanh
+	case ReLU:
+		return relu
+	case Gaussian:
+		return gaussian
+	case Sine:
+		return math.Sin
+	case Abs:
+		return math.Abs
+	case Step:
+		return step
+	case Identity:
+		return identity
+	default:
+		return sigmoid
+	}
+}
+
+func sigmoid(x float64) float64  { return 1 / (1 + math.Exp(-4.9*x)) }
+func relu(x float64) float64     { return math.Max(0, x) }
+func gaussian(x float64) float64 { return math.Exp(-x * x) }
+func identity(x float64) float64 { return x }
+
+func step(x float64) float64 {
+	if x > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Returns the activation with the default, sigmoid, made explicit
+func (a Activation) Normal() Activation {
+	if a == "" {
+		return Sigmoid
+	}
+	return a
+}
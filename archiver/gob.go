@@ -49,6 +49,7 @@ func (x *gobArchiver) Restore() (pop *neat.Population, err error) {
 	}
 	defer f.Close()
 
+	pop = new(neat.Population)
 	d := gob.NewDecoder(f)
 	err = d.Decode(pop)
 	return
@@ -49,6 +49,7 @@ func (x *xmlArchiver) Restore() (pop *neat.Population, err error) {
 	}
 	defer f.Close()
 
+	pop = new(neat.Population)
 	d := xml.NewDecoder(f)
 	err = d.Decode(pop)
 	return
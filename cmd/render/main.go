@@ -0,0 +1,123 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Command render draws a genome from an archived population as Graphviz DOT
+// or SVG.
+//
+//	render [-format svg|dot] [-genome id] [-o file] population.json
+//
+// The archive format is chosen by the file's extension: .json, .xml or .gob.
+// Without -genome the fittest organism is drawn.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/archiver"
+	"github.com/boggo/neat/render"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+
+	format := flag.String("format", "svg", "output format, svg or dot")
+	id := flag.Int("genome", 0, "ID of the genome to draw. 0 draws the fittest")
+	out := flag.String("o", "", "file to write. Standard output if empty")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	if err := run(flag.Arg(0), *format, *id, *out); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Draws the genome from the archive at path to the file out, or to standard
+// output if out is empty
+func run(path, format string, id int, out string) (err error) {
+
+	// Restore the population
+	var arch neat.Archiver
+	switch filepath.Ext(path) {
+	case ".json":
+		arch = archiver.NewJSON(path)
+	case ".xml":
+		arch = archiver.NewXML(path)
+	case ".gob":
+		arch = archiver.NewGOB(path)
+	default:
+		return fmt.Errorf("Unknown archive format %q", filepath.Ext(path))
+	}
+	pop, err := arch.Restore()
+	if err != nil {
+		return
+	}
+
+	// Find the genome
+	var genome *neat.Genome
+	for _, o := range pop.Organisms() {
+		switch {
+		case id != 0 && o.ID == id:
+			genome = o.Genome
+		case id == 0 && len(o.Fitness) > 0 && (genome == nil || o.Fitness[0] > genome.Fitness[0]):
+			genome = o.Genome
+		}
+	}
+	if genome == nil {
+		return errors.New("Genome not found")
+	}
+
+	// Draw it
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, e := os.Create(out)
+		if e != nil {
+			return e
+		}
+		defer func() {
+			if e := f.Close(); err == nil {
+				err = e
+			}
+		}()
+		w = f
+	}
+	switch format {
+	case "svg":
+		err = render.SVG(w, genome)
+	case "dot":
+		err = render.DOT(w, genome)
+	default:
+		err = fmt.Errorf("Unknown format %q", format)
+	}
+	return
+}
@@ -0,0 +1,160 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package codegen writes evolved networks out as standalone Go source, so
+// they can be deployed without the neat and neural packages.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neural"
+	"go/format"
+	"io"
+	"strconv"
+)
+
+// Helpers for the activation functions which are not in package math. They
+// are named after the generated function so several may share a package.
+var helpers = map[neat.Activation]string{
+	neat.Sigmoid:  "func %sSigmoid(x float64) float64 { return 1 / (1 + math.Exp(-4.9*x)) }",
+	neat.ReLU:     "func %sReLU(x float64) float64 { return math.Max(0, x) }",
+	neat.Gaussian: "func %sGaussian(x float64) float64 { return math.Exp(-x * x) }",
+	neat.Step:     "func %sStep(x float64) float64 {\n\tif x > 0 {\n\t\treturn 1\n\t}\n\treturn 0\n}",
+}
+
+// Writes a Go source file for package pkg holding a function, called name,
+// which computes the genome's outputs for its inputs as the phenome built by
+// decoder.NewNEAT does: the nodes are activated in the decoder's order and
+// each hidden and output node once the connections into it are summed. The
+// function panics if given too few inputs.
+func Generate(w io.Writer, genome *neat.Genome, pkg, name string) (err error) {
+
+	nodes, conns, err := decoder.Graph(genome)
+	if err != nil {
+		return
+	}
+
+	// Write the function
+	var b bytes.Buffer
+	used := make(map[neat.Activation]bool)
+	fmt.Fprintf(&b, "// %s computes the outputs of genome %d for the inputs\n", name, genome.ID)
+	fmt.Fprintf(&b, "func %s(inputs []float64) (outputs []float64) {\n", name)
+	fmt.Fprintf(&b, "var v [%d]float64\n", len(nodes))
+	in := 0
+	for i, n := range nodes {
+		switch n.Type {
+		case neural.BIAS:
+			fmt.Fprintf(&b, "v[%d] = 1\n", i)
+		case neural.INPUT:
+			fmt.Fprintf(&b, "v[%d] = inputs[%d]\n", i, in)
+			in += 1
+		default:
+			fmt.Fprintf(&b, "v[%d] = %s\n", i, apply(n.Activation, name, "0", used))
+		}
+	}
+	sum := ""
+	for i, c := range conns {
+		if sum != "" {
+			sum += " + "
+		}
+		sum += fmt.Sprintf("v[%d]*%s", c.Source, strconv.FormatFloat(c.Weight, 'g', -1, 64))
+		if i == len(conns)-1 || conns[i+1].Target != c.Target {
+			fmt.Fprintf(&b, "v[%d] = %s\n", c.Target, apply(nodes[c.Target].Activation, name, sum, used))
+			sum = ""
+		}
+	}
+	b.WriteString("outputs = []float64{")
+	first := true
+	for i, n := range nodes {
+		if n.Type == neural.OUTPUT {
+			if !first {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "v[%d]", i)
+			first = false
+		}
+	}
+	b.WriteString("}\nreturn\n}\n")
+
+	// Write the file around it
+	var f bytes.Buffer
+	fmt.Fprintf(&f, "// Code generated by github.com/boggo/neat/codegen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if needsMath(used) {
+		f.WriteString("import \"math\"\n\n")
+	}
+	f.Write(b.Bytes())
+	for _, a := range []neat.Activation{neat.Sigmoid, neat.ReLU, neat.Gaussian, neat.Step} {
+		if used[a] {
+			fmt.Fprintf(&f, "\n"+helpers[a]+"\n", name)
+		}
+	}
+
+	// Tidy and write it out
+	src, err := format.Source(f.Bytes())
+	if err != nil {
+		return
+	}
+	_, err = w.Write(src)
+	return
+}
+
+// Returns the expression applying the activation to x within the function
+// called name, noting its use
+func apply(a neat.Activation, name, x string, used map[neat.Activation]bool) string {
+	a = a.Normal()
+	used[a] = true
+	switch a {
+	case neat.Tanh:
+		return "math.Tanh(" + x + ")"
+	case neat.Sine:
+		return "math.Sin(" + x + ")"
+	case neat.Abs:
+		return "math.Abs(" + x + ")"
+	case neat.Identity:
+		return x
+	case neat.ReLU:
+		return name + "ReLU(" + x + ")"
+	case neat.Gaussian:
+		return name + "Gaussian(" + x + ")"
+	case neat.Step:
+		return name + "Step(" + x + ")"
+	default:
+		return name + "Sigmoid(" + x + ")"
+	}
+}
+
+// Returns true if any of the activations used needs package math
+func needsMath(used map[neat.Activation]bool) bool {
+	for a := range used {
+		if a != neat.Identity && a != neat.Step {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package codegen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neat/popeval"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Gives every organism a random fitness
+type randomEval struct {
+	random *rand.Rand
+}
+
+func (e randomEval) Evaluate(org *neat.Organism) (err error) {
+	org.Fitness = []float64{e.random.Float64()}
+	return
+}
+
+// Generates code for evolved genomes, runs it on random inputs and compares
+// its outputs to those of the decoded phenome
+func TestRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs generated code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	// Evolve genomes with varied structure, both sigmoid only, which the NEAT
+	// decoder builds as a neural.Network, and with mixed activation functions
+	orgs := evolve(t, nil)
+	orgs = append(orgs, evolve(t, []neat.Activation{neat.Tanh, neat.Sigmoid, neat.ReLU, neat.Gaussian,
+		neat.Sine, neat.Abs, neat.Step, neat.Identity})...)
+
+	// Write a program which prints each generated function's outputs
+	r := rand.New(rand.NewSource(1))
+	inputs := make([][]float64, 10)
+	for i := range inputs {
+		inputs[i] = []float64{r.Float64()*4 - 2, r.Float64()*4 - 2, r.Float64()*4 - 2}
+	}
+	dir := t.TempDir()
+	var main bytes.Buffer
+	main.WriteString("package main\n\nimport \"fmt\"\n\nfunc main() {\n")
+	for i, o := range orgs {
+		var src bytes.Buffer
+		err := Generate(&src, o.Genome, "main", fmt.Sprintf("net%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, fmt.Sprintf("net%d.go", i)), src.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, in := range inputs {
+			fmt.Fprintf(&main, "\tfor _, x := range net%d(%#v) {\n\t\tfmt.Println(x)\n\t}\n", i, in)
+		}
+	}
+	main.WriteString("}\n")
+	err := os.WriteFile(filepath.Join(dir, "main.go"), main.Bytes(), 0644)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module generated\n\ngo 1.18\n"), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	// Compare the outputs
+	lines := strings.Fields(string(out))
+	for i, o := range orgs {
+		p, err := decoder.NewNEAT().Decode(o.Genome)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, in := range inputs {
+			want, err := p.Analyze(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range want {
+				if len(lines) == 0 {
+					t.Fatal("Generated code produced too few outputs")
+				}
+				got, err := strconv.ParseFloat(lines[0], 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines = lines[1:]
+				if math.Abs(got-w) > 1e-9 {
+					t.Errorf("Genome %d (net%d) on %v: got %v, want %v", o.ID, i, in, got, w)
+				}
+			}
+		}
+	}
+	if len(lines) > 0 {
+		t.Errorf("Generated code produced %d extra outputs", len(lines))
+	}
+}
+
+// Evolves a small population whose nodes may use the activations, returning
+// its organisms
+func evolve(t *testing.T, activations []neat.Activation) neat.OrganismSlice {
+	settings := &neat.Settings{PopulationSize: 20, BiasCount: 1, InputCount: 3, OutputCount: 2,
+		ExcessCoefficient: 1, DisjointCoefficient: 1, WeightCoefficient: 0.4,
+		MutateWeight: 0.8, MutateWeightNew: 0.1, MutateEnabled: 0.05,
+		MutateAddConnection: 0.4, MutateAddNode: 0.3, MutateFuncType: 0.3, Activations: activations,
+		Crossover: 0.75, AgeToStagnation: 15, SurvivalPercent: 0.2, EliteCount: 1,
+		CompatThreshold: 3, Seed: 1}
+	pop, err := neat.Run(context.Background(), settings, neat.MaxGenerations(10), decoder.NewNEAT(),
+		popeval.NewSerial(), randomEval{rand.New(rand.NewSource(1))}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pop.Organisms()
+}
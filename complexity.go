@@ -0,0 +1,171 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+)
+
+// Complexity records where a population is within its complexity strategy.
+// It is kept with the population so a restored run continues in the same
+// phase.
+type Complexity struct {
+	Simplifying bool    // Is the search currently simplifying?
+	Threshold   float64 // Mean population complexity at which to begin simplifying
+	LastMPC     float64 // Mean population complexity at the previous generation
+	Stalled     int     // Generations in which simplifying has failed to lower the MPC
+}
+
+// ComplexityStrategy controls how the population's complexity grows and
+// shrinks over a run. Before each generation is bred, Adjust updates the
+// population's Complexity and returns the settings to breed it with. The
+// settings passed in belong to the caller and must not be modified.
+type ComplexityStrategy interface {
+	Adjust(settings *Settings, pop *Population) (effective *Settings)
+}
+
+var complexityStrategies = map[string]ComplexityStrategy{
+	"":           phased{}, // Without a PruneThreshold this never simplifies
+	"complexify": complexify{},
+	"phased":     phased{},
+	"blended":    blended{},
+}
+
+// Registers a complexity strategy so it may be chosen by name through
+// Settings.ComplexityStrategy. Registration should happen during program
+// initialization and replaces any strategy of the same name.
+func RegisterComplexity(name string, cs ComplexityStrategy) {
+	complexityStrategies[name] = cs
+}
+
+// Returns the complexity strategy named in the settings
+func complexityStrategy(settings *Settings) (cs ComplexityStrategy, err error) {
+	cs, ok := complexityStrategies[settings.ComplexityStrategy]
+	if !ok {
+		err = fmt.Errorf("Unknown complexity strategy %q", settings.ComplexityStrategy)
+	}
+	return
+}
+
+// Returns a copy of the settings which only adds structure
+func complexifying(settings *Settings) *Settings {
+	eff := *settings
+	eff.MutateDelNode = 0
+	eff.MutateDelConnection = 0
+	return &eff
+}
+
+// Returns a copy of the settings which only removes structure
+func simplifying(settings *Settings) *Settings {
+	eff := *settings
+	eff.MutateAddNode = 0
+	eff.MutateAddConnection = 0
+	eff.Crossover = 0
+	return &eff
+}
+
+// Traditional NEAT. Structure is only ever added.
+type complexify struct{}
+
+func (cs complexify) Adjust(settings *Settings, pop *Population) *Settings {
+	pop.Complexity.Simplifying = false
+	return complexifying(settings)
+}
+
+// Phased search as described at http://sharpneat.sourceforge.net/phasedsearch.html.
+// The population complexifies until its mean complexity rises PruneThreshold
+// above where the phase began. It then simplifies until the mean complexity
+// has not fallen for PruneFloor generations and begins complexifying again.
+type phased struct{}
+
+func (cs phased) Adjust(settings *Settings, pop *Population) *Settings {
+
+	c := &pop.Complexity
+	mpc := pop.MPC()
+	if c.Threshold == 0 {
+		c.Threshold = mpc + settings.PruneThreshold
+		c.LastMPC = mpc
+	}
+
+	if !c.Simplifying {
+		if settings.PruneThreshold > 0 && mpc >= c.Threshold {
+			c.Simplifying = true
+			c.Stalled = 0
+		}
+	} else {
+		if mpc < c.LastMPC {
+			c.Stalled = 0
+		} else {
+			c.Stalled += 1
+		}
+		if c.Stalled > settings.PruneFloor {
+			c.Simplifying = false
+			c.Threshold = mpc + settings.PruneThreshold
+		}
+	}
+	c.LastMPC = mpc
+
+	if c.Simplifying {
+		return simplifying(settings)
+	}
+	return complexifying(settings)
+}
+
+// Adds and removes structure at the same time. The deletion rates are scaled
+// up, and the addition rates down, as the mean complexity climbs through the
+// PruneThreshold band above the initial complexity.
+type blended struct{}
+
+func (cs blended) Adjust(settings *Settings, pop *Population) *Settings {
+
+	c := &pop.Complexity
+	mpc := pop.MPC()
+	if c.Threshold == 0 {
+		c.Threshold = mpc + settings.PruneThreshold
+	}
+	c.LastMPC = mpc
+
+	// Position within the band, 0 at its floor and 1 at its ceiling
+	t := float64(0)
+	if settings.PruneThreshold > 0 {
+		t = (mpc - c.Threshold + settings.PruneThreshold) / settings.PruneThreshold
+	}
+	if t < 0 {
+		t = 0
+	}
+	if t > 1 {
+		t = 1
+	}
+	c.Simplifying = t > 0.5
+
+	eff := *settings
+	eff.MutateAddNode *= 1 - t
+	eff.MutateAddConnection *= 1 - t
+	eff.MutateDelNode *= t
+	eff.MutateDelConnection *= t
+	return &eff
+}
@@ -0,0 +1,179 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder
+
+import (
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Builds a layered, fully connected genome with the given layer sizes. The
+// first layer holds a bias and the inputs, the last the outputs.
+func benchGenome(sizes ...int) *neat.Genome {
+	g := &neat.Genome{ID: 1, Nodes: make(neat.NodeGeneMap), Conns: make(neat.ConnGeneMap)}
+	r := rand.New(rand.NewSource(1))
+	marker := 0
+	var prev []*neat.NodeGene
+	for l, size := range sizes {
+		layer := make([]*neat.NodeGene, size)
+		for i := range layer {
+			marker += 1
+			ng := &neat.NodeGene{Marker: marker, Type: neural.HIDDEN,
+				X: float64(i) / float64(size), Y: float64(l) / float64(len(sizes)-1)}
+			switch {
+			case l == 0 && i == 0:
+				ng.Type = neural.BIAS
+			case l == 0:
+				ng.Type = neural.INPUT
+			case l == len(sizes)-1:
+				ng.Type = neural.OUTPUT
+			}
+			g.Nodes[marker] = ng
+			layer[i] = ng
+		}
+		for _, src := range prev {
+			for _, tgt := range layer {
+				marker += 1
+				g.Conns[marker] = &neat.ConnGene{Marker: marker, Source: src.Marker, Target: tgt.Marker,
+					Weight: r.NormFloat64(), Enabled: true}
+			}
+		}
+		prev = layer
+	}
+	return g
+}
+
+// Activates a phenome decoded from a mid-sized genome
+func benchmarkAnalyze(b *testing.B, d neat.Decoder) {
+	p, err := d.Decode(benchGenome(9, 16, 16, 16, 4))
+	if err != nil {
+		b.Fatal(err)
+	}
+	inputs := []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = p.Analyze(inputs)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAnalyzeNEAT(b *testing.B)     { benchmarkAnalyze(b, NewNEAT()) }
+func BenchmarkAnalyzeCompiled(b *testing.B) { benchmarkAnalyze(b, NewCompiled()) }
+
+// Builds a random feed-forward genome. Connections only lead to nodes further
+// down, some are disabled, and some hidden and output nodes are left without
+// incoming connections. The hidden and output nodes take activations from
+// acts, where the empty activation is the default sigmoid.
+func randomGenome(r *rand.Rand, inputs, hidden, outputs int, acts []neat.Activation) *neat.Genome {
+	g := &neat.Genome{ID: 1, Nodes: make(neat.NodeGeneMap), Conns: make(neat.ConnGeneMap)}
+	marker := 0
+	add := func(t neural.NodeType, y float64) {
+		marker += 1
+		ng := &neat.NodeGene{Marker: marker, Type: t, X: r.Float64(), Y: y}
+		if t == neural.HIDDEN || t == neural.OUTPUT {
+			ng.Activation = acts[r.Intn(len(acts))]
+		}
+		g.Nodes[marker] = ng
+	}
+	add(neural.BIAS, 0)
+	for i := 0; i < inputs; i++ {
+		add(neural.INPUT, 0)
+	}
+	for i := 0; i < outputs; i++ {
+		add(neural.OUTPUT, 1)
+	}
+	for i := 0; i < hidden; i++ {
+		add(neural.HIDDEN, 0.1+0.8*r.Float64())
+	}
+	n := marker
+	for src := 1; src <= n; src++ {
+		for tgt := 1; tgt <= n; tgt++ {
+			if g.Nodes[src].Y >= g.Nodes[tgt].Y || r.Float64() > 0.4 {
+				continue
+			}
+			marker += 1
+			g.Conns[marker] = &neat.ConnGene{Marker: marker, Source: src, Target: tgt,
+				Weight: 2 * r.NormFloat64(), Enabled: r.Float64() > 0.2}
+		}
+	}
+	return g
+}
+
+// Checks that the compiled phenome computes what the NEAT decoder's does
+func TestCompiledMatchesNEAT(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	mixed := []neat.Activation{"", neat.Sigmoid, neat.Tanh, neat.ReLU, neat.Gaussian,
+		neat.Sine, neat.Abs, neat.Step, neat.Identity}
+	for i := 0; i < 200; i++ {
+		acts := mixed
+		if i%2 == 0 {
+			acts = []neat.Activation{""} // Decoded by NEAT as a neural.Network
+		}
+		g := randomGenome(r, 1+r.Intn(5), r.Intn(12), 1+r.Intn(4), acts)
+		want, err := NewNEAT().Decode(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewCompiled().Decode(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs := make([]float64, len(g.Nodes))
+		for j := 0; j < 5; j++ {
+			n := 0
+			for _, ng := range g.Nodes {
+				if ng.Type == neural.INPUT {
+					inputs[n] = 4*r.Float64() - 2
+					n++
+				}
+			}
+			wo, err := want.Analyze(inputs[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			wo = append([]float64(nil), wo...)
+			out, err := got.Analyze(inputs[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) != len(wo) {
+				t.Fatalf("genome %d: %d outputs, want %d", i, len(out), len(wo))
+			}
+			for k := range wo {
+				if math.Abs(out[k]-wo[k]) > 1e-9 {
+					t.Fatalf("genome %d, inputs %v: output %d is %v, want %v", i, inputs[:n], k, out[k], wo[k])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/phenome"
+	"github.com/boggo/neural"
+	"math"
+)
+
+// A position on the substrate. Z is ignored by 2-D substrates.
+type Point struct {
+	X, Y, Z float64
+}
+
+// Substrate lays out the nodes of the network built by the HyperNEAT decoder.
+// The layers are fully connected in order, from the inputs through the hidden
+// layers to the outputs, and the bias nodes connect to every hidden and output
+// node.
+type Substrate struct {
+	Dims    int       // Dimensions of the substrate, 2 (default) or 3
+	Bias    []Point   // Bias nodes
+	Inputs  []Point   // Input nodes
+	Hidden  [][]Point // Hidden layers, in order
+	Outputs []Point   // Output nodes
+}
+
+// HyperNEAT decoder
+type hyperNEATDecoder struct {
+	cppn      neat.Decoder
+	substrate *Substrate
+	threshold float64
+	scale     float64
+}
+
+// Returns a new HyperNEAT decoder (Stanley, D'Ambrosio and Gauci, 2009). The
+// genome is decoded by the NEAT decoder into a CPPN, which is queried with the
+// coordinates of the source and target of each possible connection on the
+// substrate, (x1, y1, x2, y2) or (x1, y1, z1, x2, y2, z2) in 3-D, so the genome
+// must have 4 or 6 inputs. The first output, mapped from [0, 1] onto [-1, 1],
+// decides the weight: connections whose magnitude is at or below threshold are
+// left out and the rest are rescaled onto (0, scale] keeping their sign. The
+// threshold must be in [0, 1) and outputs outside [0, 1] are clamped to it.
+func NewHyperNEAT(substrate *Substrate, threshold, scale float64) (decoder neat.Decoder) {
+	return &hyperNEATDecoder{cppn: NewNEAT(), substrate: substrate, threshold: threshold, scale: scale}
+}
+
+// Decodes a genome into a phenome by querying its CPPN over the substrate
+func (d hyperNEATDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error) {
+
+	// Decode the CPPN
+	if !(d.threshold >= 0 && d.threshold < 1) {
+		err = fmt.Errorf("HyperNEAT threshold must be in [0, 1), not %v", d.threshold)
+		return
+	}
+	cppn, err := d.cppn.Decode(genome)
+	if err != nil {
+		return
+	}
+	dims := d.substrate.Dims
+	if dims == 0 {
+		dims = 2
+	}
+	if dims != 2 && dims != 3 {
+		err = fmt.Errorf("Substrate must have 2 or 3 dimensions, not %d", dims)
+		return
+	}
+	if len(d.substrate.Outputs) == 0 {
+		err = errors.New("Substrate has no outputs")
+		return
+	}
+	query := make([]float64, 2*dims)
+
+	// Add the nodes, layer by layer
+	network := &neural.Network{}
+	add := func(pts []Point, t neural.NodeType, f neural.FuncType) (nodes []neural.Node) {
+		nodes = make([]neural.Node, len(pts))
+		for i := range pts {
+			nodes[i] = neural.NewNode(f, t)
+			network.AddNode(nodes[i])
+		}
+		return
+	}
+	bias := add(d.substrate.Bias, neural.BIAS, neural.DIRECT)
+	inputs := add(d.substrate.Inputs, neural.INPUT, neural.DIRECT)
+	hidden := make([][]neural.Node, len(d.substrate.Hidden))
+	for i, h := range d.substrate.Hidden {
+		hidden[i] = add(h, neural.HIDDEN, neural.SIGMOID)
+	}
+	outputs := add(d.substrate.Outputs, neural.OUTPUT, neural.SIGMOID)
+
+	// Connect each layer to the next, and the bias to every layer after the
+	// inputs
+	connect := func(srcPts []Point, srcs []neural.Node, tgtPts []Point, tgts []neural.Node) (err error) {
+		for j, tp := range tgtPts {
+			for i, sp := range srcPts {
+				if dims == 2 {
+					query[0], query[1], query[2], query[3] = sp.X, sp.Y, tp.X, tp.Y
+				} else {
+					query[0], query[1], query[2], query[3], query[4], query[5] = sp.X, sp.Y, sp.Z, tp.X, tp.Y, tp.Z
+				}
+				var out []float64
+				out, err = cppn.Analyze(query)
+				if err != nil {
+					return
+				}
+				if w := d.weight(out[0]); w != 0 {
+					network.AddConnection(neural.NewConnection(srcs[i], tgts[j], w))
+				}
+			}
+		}
+		return
+	}
+	srcPts, srcs := d.substrate.Inputs, inputs
+	for i := 0; i <= len(hidden); i++ {
+		tgtPts, tgts := d.substrate.Outputs, outputs
+		if i < len(hidden) {
+			tgtPts, tgts = d.substrate.Hidden[i], hidden[i]
+		}
+		err = connect(d.substrate.Bias, bias, tgtPts, tgts)
+		if err == nil {
+			err = connect(srcPts, srcs, tgtPts, tgts)
+		}
+		if err != nil {
+			return
+		}
+		srcPts, srcs = tgtPts, tgts
+	}
+	// Return the phenome
+	pnome = phenome.NewNetwork(network)
+	return
+}
+
+// Returns the connection weight for the CPPN's output. Outputs outside [0, 1],
+// from activations other than sigmoid, are clamped so the weight never
+// exceeds the scale or changes sign.
+func (d hyperNEATDecoder) weight(out float64) float64 {
+	w := 2*math.Max(0, math.Min(1, out)) - 1
+	a := math.Abs(w)
+	if a <= d.threshold {
+		return 0
+	}
+	return math.Copysign((a-d.threshold)/(1-d.threshold)*d.scale, w)
+}
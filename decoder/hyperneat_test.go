@@ -0,0 +1,82 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder
+
+import (
+	"math"
+	"testing"
+)
+
+// Checks the weights given for CPPN outputs inside and outside [0, 1]
+func TestHyperNEATWeight(t *testing.T) {
+	d := hyperNEATDecoder{threshold: 0.2, scale: 3}
+	tests := []struct {
+		out, want float64
+	}{
+		{0.5, 0},
+		{0.55, 0},
+		{1, 3},
+		{0, -3},
+		{0.8, 1.5},
+		{0.2, -1.5},
+		{1.5, 3},
+		{-0.5, -3},
+		{-4, -3},
+	}
+	for _, tt := range tests {
+		if got := d.weight(tt.out); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Got weight %v for output %v, want %v", got, tt.out, tt.want)
+		}
+	}
+}
+
+// Checks that decoding rejects thresholds outside [0, 1)
+func TestHyperNEATThreshold(t *testing.T) {
+	substrate := &Substrate{Bias: []Point{{0, 0, 0}}, Inputs: []Point{{-1, -1, 0}, {1, -1, 0}},
+		Outputs: []Point{{0, 1, 0}}}
+	cppn := benchGenome(5, 1)
+	for _, threshold := range []float64{0, 0.2, 0.99, -0.1, 1, 2, math.NaN()} {
+		valid := threshold >= 0 && threshold < 1
+		p, err := NewHyperNEAT(substrate, threshold, 3).Decode(cppn)
+		if valid && err != nil {
+			t.Errorf("Threshold %v: %v", threshold, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("Threshold %v was accepted", threshold)
+		}
+		if err != nil {
+			continue
+		}
+		out, err := p.Analyze([]float64{0.3, 0.7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.IsNaN(out[0]) || math.IsInf(out[0], 0) {
+			t.Errorf("Threshold %v: got output %v", threshold, out[0])
+		}
+	}
+}
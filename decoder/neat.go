@@ -27,6 +27,7 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package decoder
 
 import (
+	"fmt"
 	"github.com/boggo/neat"
 	"github.com/boggo/neat/phenome"
 	"github.com/boggo/neural" // TODO: Should this library be moved to code.google.com, too?
@@ -44,25 +45,15 @@ func NewNEAT() (decoder neat.Decoder) {
 // Decodes a genome into a phenome using the NEAT decoder
 func (d neatDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error) {
 
-	// Extract the nodes into the correct sorted order (by its position)
-	nodes := make([]*neat.NodeGene, len(genome.Nodes))
-	i := 0
-	for _, v := range genome.Nodes {
-		nodes[i] = v
-		i += 1
-	}
-	sn := &sortNodes{nodes}
-	sort.Sort(sn)
+	nodes, conns := sorted(genome)
 
-	// Extract the connections into the corrected sorted order (by target node's position)
-	conns := make([]*neat.ConnGene, len(genome.Conns))
-	i = 0
-	for _, v := range genome.Conns {
-		conns[i] = v
-		i += 1
+	// Networks using functions other than sigmoid are built as graphs
+	for _, ng := range nodes {
+		if ng.Type != neural.BIAS && ng.Type != neural.INPUT && ng.Activation.Normal() != neat.Sigmoid {
+			pnome, err = decodeGraph(nodes, conns)
+			return
+		}
 	}
-	sc := &sortConns{genome, conns}
-	sort.Sort(sc)
 
 	// Build the network
 	network := &neural.Network{}
@@ -86,9 +77,101 @@ func (d neatDecoder) Decode(genome *neat.Genome) (pnome neat.Phenome, err error)
 		}
 	}
 
-	// Return the phenome
+	// Return the phenome. When every connection leads forward the network
+	// holds no state between rows, so batches are left to a compiled copy
+	// which reuses its buffers. Otherwise rows are analyzed one at a time.
 	//network.Dump()
 	pnome = phenome.NewNetwork(network)
+	if forward(nodes, conns) {
+		gnodes, gconns, e := graph(nodes, conns)
+		var batch neat.Phenome
+		if e == nil {
+			batch, e = phenome.NewCompiled(gnodes, gconns)
+		}
+		if e == nil {
+			pnome = phenome.NewNetworkBatch(network, batch.(neat.BatchPhenome))
+		}
+	}
+	return
+}
+
+// Returns true if every enabled connection leads to a node later in the
+// sorted order
+func forward(nodes []*neat.NodeGene, conns []*neat.ConnGene) bool {
+	index := make(map[int]int, len(nodes))
+	for i, ng := range nodes {
+		index[ng.Marker] = i
+	}
+	for _, cg := range conns {
+		if cg.Enabled && index[cg.Source] >= index[cg.Target] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the genome's nodes sorted by position and its connections sorted by
+// the position of their targets
+func sorted(genome *neat.Genome) (nodes []*neat.NodeGene, conns []*neat.ConnGene) {
+
+	// Extract the nodes into the correct sorted order (by its position)
+	nodes = make([]*neat.NodeGene, len(genome.Nodes))
+	i := 0
+	for _, v := range genome.Nodes {
+		nodes[i] = v
+		i += 1
+	}
+	sn := &sortNodes{nodes}
+	sort.Sort(sn)
+
+	// Extract the connections into the corrected sorted order (by target node's position)
+	conns = make([]*neat.ConnGene, len(genome.Conns))
+	i = 0
+	for _, v := range genome.Conns {
+		conns[i] = v
+		i += 1
+	}
+	sc := &sortConns{genome, conns}
+	sort.Sort(sc)
+	return
+}
+
+// Returns the genome's nodes and enabled connections in the order the NEAT
+// decoder activates them, as for a graph phenome
+func Graph(genome *neat.Genome) (nodes []phenome.GraphNode, conns []phenome.GraphConn, err error) {
+	return graph(sorted(genome))
+}
+
+// Builds a graph phenome from the sorted nodes and connections
+func decodeGraph(nodes []*neat.NodeGene, conns []*neat.ConnGene) (pnome neat.Phenome, err error) {
+	gnodes, gconns, err := graph(nodes, conns)
+	if err == nil {
+		pnome = phenome.NewGraph(gnodes, gconns)
+	}
+	return
+}
+
+// Converts the sorted nodes and enabled connections for a graph phenome
+func graph(nodes []*neat.NodeGene, conns []*neat.ConnGene) (gnodes []phenome.GraphNode,
+	gconns []phenome.GraphConn, err error) {
+
+	gnodes = make([]phenome.GraphNode, len(nodes))
+	index := make(map[int]int, len(nodes))
+	for i, ng := range nodes {
+		if !ng.Activation.Known() {
+			err = fmt.Errorf("Node %d has unknown activation %q", ng.Marker, ng.Activation)
+			return
+		}
+		gnodes[i] = phenome.GraphNode{Type: ng.Type, Activation: ng.Activation}
+		index[ng.Marker] = i
+	}
+	gconns = make([]phenome.GraphConn, 0, len(conns))
+	for _, cg := range conns {
+		if cg.Enabled {
+			gconns = append(gconns, phenome.GraphConn{Source: index[cg.Source], Target: index[cg.Target],
+				Weight: cg.Weight})
+		}
+	}
 	return
 }
 
@@ -99,6 +182,9 @@ type sortNodes struct {
 func (s *sortNodes) Len() int { return len(s.nodes) }
 func (s *sortNodes) Less(i, j int) bool {
 	if s.nodes[i].Y == s.nodes[j].Y {
+		if s.nodes[i].X == s.nodes[j].X {
+			return s.nodes[i].Marker < s.nodes[j].Marker // Keep the order reproducible
+		}
 		return s.nodes[i].X < s.nodes[j].X
 	} else {
 		return s.nodes[i].Y < s.nodes[j].Y
@@ -116,6 +202,12 @@ func (s *sortConns) Less(i, j int) bool {
 	a := s.genome.Nodes[s.conns[i].Target]
 	b := s.genome.Nodes[s.conns[j].Target]
 	if a.Y == b.Y {
+		if a.X == b.X {
+			if a.Marker == b.Marker {
+				return s.conns[i].Marker < s.conns[j].Marker // Keep the order reproducible
+			}
+			return a.Marker < b.Marker
+		}
 		return a.X < b.X
 	} else {
 		return a.Y < b.Y
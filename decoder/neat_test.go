@@ -0,0 +1,127 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package decoder
+
+import (
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Returns rows of inputs for the mid-sized benchmark genome
+func benchRows(n int) (rows [][]float64) {
+	r := rand.New(rand.NewSource(1))
+	rows = make([][]float64, n)
+	for i := range rows {
+		rows[i] = make([]float64, 8)
+		for j := range rows[i] {
+			rows[i][j] = 2*r.Float64() - 1
+		}
+	}
+	return
+}
+
+// Analyzes a batch of rows one row at a time, as callers did before batches
+func BenchmarkAnalyzeRowsNEAT(b *testing.B) {
+	p, err := NewNEAT().Decode(benchGenome(9, 16, 16, 16, 4))
+	if err != nil {
+		b.Fatal(err)
+	}
+	rows := benchRows(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, in := range rows {
+			_, err = p.Analyze(in)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Analyzes the same batch of rows in one call
+func BenchmarkAnalyzeBatchNEAT(b *testing.B) {
+	p, err := NewNEAT().Decode(benchGenome(9, 16, 16, 16, 4))
+	if err != nil {
+		b.Fatal(err)
+	}
+	rows := benchRows(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = neat.AnalyzeBatch(p, rows)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Checks that sigmoid networks analyze batches as they do single rows
+func TestNEATBatchMatchesRows(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGenome(r, 1+r.Intn(5), r.Intn(12), 1+r.Intn(4), []neat.Activation{""})
+		p, err := NewNEAT().Decode(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, ng := range g.Nodes {
+			if ng.Type == neural.INPUT {
+				n++
+			}
+		}
+		rows := make([][]float64, 5)
+		for j := range rows {
+			rows[j] = make([]float64, n)
+			for k := range rows[j] {
+				rows[j][k] = 4*r.Float64() - 2
+			}
+		}
+		got, err := neat.AnalyzeBatch(p, rows)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, in := range rows {
+			want, err := p.Analyze(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got[j]) != len(want) {
+				t.Fatalf("genome %d, row %d: %d outputs, want %d", i, j, len(got[j]), len(want))
+			}
+			for k := range want {
+				if math.Abs(got[j][k]-want[k]) > 1e-9 {
+					t.Fatalf("genome %d, row %d: output %d is %v, want %v", i, j, k, got[j][k], want[k])
+				}
+			}
+		}
+	}
+}
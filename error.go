@@ -0,0 +1,97 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Phase identifies the part of a run in which an error occurred
+type Phase int
+
+const (
+	PhaseRestore    Phase = iota // Restoring the population from the archiver
+	PhaseInitialize              // Creating the initial population
+	PhaseRoll                    // Rolling the population to the next generation
+	PhaseDecode                  // Decoding genomes into phenomes
+	PhaseEvaluate                // Evaluating the population
+	PhaseArchive                 // Archiving the population
+	PhaseReport                  // Reporting on the population
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseRestore:
+		return "restore"
+	case PhaseInitialize:
+		return "initialize"
+	case PhaseRoll:
+		return "roll"
+	case PhaseDecode:
+		return "decode"
+	case PhaseEvaluate:
+		return "evaluate"
+	case PhaseArchive:
+		return "archive"
+	case PhaseReport:
+		return "report"
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// RunError describes a failure during Run along with the phase and
+// generation in which it happened. If the run was cancelled, Err is the
+// context's error.
+type RunError struct {
+	Phase      Phase // Phase which failed
+	Generation int   // Generation being processed at the time
+	Err        error // Underlying error
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("neat: %v failed in generation %d: %v", e.Phase, e.Generation, e.Err)
+}
+
+// Returns the underlying error so RunError works with errors.Is and errors.As
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// Joins the error from archiving the last population of a cancelled run into
+// the run's error so neither is lost
+func joinArchive(err error, generation int, archErr error) error {
+	if archErr == nil {
+		return err
+	}
+	archErr = fmt.Errorf("archiving generation %d: %w", generation, archErr)
+	if re, ok := err.(*RunError); ok {
+		re.Err = errors.Join(re.Err, archErr)
+		return re
+	}
+	return errors.Join(err, archErr)
+}
@@ -29,7 +29,9 @@ Copyright (C) 2013 Brian Hummer (brian@boggo.net)
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
 	//"fmt"
 	"github.com/boggo/neat"
 	"github.com/boggo/neat/archiver"
@@ -179,6 +181,11 @@ func (eval singPoleEval) Evaluate(org *neat.Organism) (err error) {
 		return
 	}
 
+	// Start the episode from a clean state
+	if rp, ok := org.Phenome.(neat.RecurrentPhenome); ok {
+		rp.Reset()
+	}
+
 	twelve_degrees := float64(0.2094384) // radians
 	num_steps := int(math.Pow(10, 5))
 
@@ -227,17 +234,28 @@ func (eval singPoleEval) Evaluate(org *neat.Organism) (err error) {
 	return
 }
 
+// Balances the pole with feed-forward networks. With -recurrent the networks
+// may also evolve recurrent connections and are decoded to recurrent phenomes.
+// These update synchronously, so each connection delays its signal by one
+// step and the outputs lag behind the pole's state; results are not
+// comparable with the feed-forward runs, which keep their own files.
 func main() {
+	recurrent := flag.Bool("recurrent", false, "evolve recurrent networks")
+	flag.Parse()
+	prefix := "singpole"
+	if *recurrent {
+		prefix = "singpole-recurrent"
+	}
 
 	// Load the settings
-	ldr := settings.NewJSON("singpole-settings.json")
+	ldr := settings.NewJSON(prefix + "-settings.json")
 	s, err := ldr.Load()
 	if err != nil {
 		panic(err)
 	}
 
 	// Create the archiver
-	a := archiver.NewJSON("singpole-pop.json")
+	a := archiver.NewJSON(prefix + "-pop.json")
 
 	// Create the reporter
 	r := reporter.NewConsole()
@@ -247,8 +265,15 @@ func main() {
 	p := popeval.NewConcurrent()
 
 	// Create the decoder
-	d := decoder.NewNEAT()
+	var d neat.Decoder = decoder.NewNEAT()
+	if *recurrent {
+		d = decoder.NewRecurrent()
+	}
 
-	// Iterate the experiment
-	neat.Iterate(s, 25, d, p, o, a, r)
+	// Run the experiment until it is solved or the generations run out
+	c := neat.Or(neat.MaxGenerations(25), neat.FitnessTarget(1e5))
+	_, err = neat.Run(context.Background(), s, c, d, p, o, a, r)
+	if err != nil {
+		panic(err)
+	}
 }
@@ -30,6 +30,7 @@ Copyright (C) 2013 Brian Hummer (brian@boggo.net)
 package main
 
 import (
+	"context"
 	"errors"
 	"github.com/boggo/neat"
 	"github.com/boggo/neat/archiver"
@@ -80,14 +81,13 @@ func (eval xorEval) Evaluate(org *neat.Organism) (err error) {
 		return
 	}
 
+	outputs, err := neat.AnalyzeBatch(org.Phenome, INPUTS)
+	if err != nil {
+		org.Fitness = []float64{0}
+		return
+	}
 	e := float64(0)
-	for i, inputs := range INPUTS {
-		output, err2 := org.Analyze(inputs)
-		if err2 != nil {
-			err = err2
-			org.Fitness = []float64{0}
-			return
-		}
+	for i, output := range outputs {
 		e += (output[0] - OUTPUTS[i]) * (output[0] - OUTPUTS[i])
 	}
 	org.Fitness = []float64{float64(1) - math.Sqrt(e/float64(len(OUTPUTS)))}
@@ -117,6 +117,10 @@ func main() {
 	// Create the decoder
 	d := decoder.NewNEAT()
 
-	// Iterate the experiment
-	neat.Iterate(s, 100, d, p, o, a, r)
+	// Run the experiment until it is solved or the generations run out
+	c := neat.Or(neat.MaxGenerations(100), neat.FitnessTarget(0.9))
+	_, err = neat.Run(context.Background(), s, c, d, p, o, a, r)
+	if err != nil {
+		panic(err)
+	}
 }
@@ -28,8 +28,10 @@ package neat
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"github.com/boggo/neural"
+	"sort"
 	"strconv"
 )
 
@@ -43,6 +45,9 @@ type NodeGene struct {
 	Marker int             // Innovation marker for this gene
 	Type   neural.NodeType // Network node type
 	X, Y   float64         // 2-D Position of this node within the network
+
+	// Function of a hidden or output node. Empty is sigmoid.
+	Activation Activation
 }
 
 func (ng NodeGene) String() string {
@@ -59,6 +64,9 @@ func (ng NodeGene) String() string {
 	default:
 		t = "UNKNOWN"
 	}
+	if ng.Activation != "" {
+		t += " " + string(ng.Activation)
+	}
 	return fmt.Sprintf("NodeGene [%4d] %7v at %3.2f, %3.2f", ng.Marker, t, ng.X, ng.Y)
 }
 
@@ -104,8 +112,43 @@ func (im *NodeGeneMap) UnmarshalJSON(bytes []byte) (err error) {
 
 }
 
+// XML has no representation for maps so the genes are written as a list
+func (im NodeGeneMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	genes := make([]*NodeGene, 0, len(im))
+	for _, m := range im.markers() {
+		genes = append(genes, im[m])
+	}
+	return e.EncodeElement(struct{ Gene []*NodeGene }{genes}, start)
+}
+
+func (im *NodeGeneMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	var genes struct{ Gene []*NodeGene }
+	err = d.DecodeElement(&genes, &start)
+	if err != nil {
+		return
+	}
+	*im = make(map[int]*NodeGene)
+	for _, g := range genes.Gene {
+		(*im)[g.Marker] = g
+	}
+	return
+}
+
+// Returns the markers of the node genes in ascending order. Ranging over the
+// map itself visits the genes in a random order which would make runs
+// irreproducible.
+func (im NodeGeneMap) markers() []int {
+	ms := make([]int, 0, len(im))
+	for k := range im {
+		ms = append(ms, k)
+	}
+	sort.Ints(ms)
+	return ms
+}
+
 func cloneNode(source *NodeGene) (clone *NodeGene) {
-	clone = &NodeGene{Marker: source.Marker, Type: source.Type, X: source.X, Y: source.Y}
+	clone = &NodeGene{Marker: source.Marker, Type: source.Type, X: source.X, Y: source.Y,
+		Activation: source.Activation}
 	return
 }
 
@@ -159,6 +202,38 @@ func (im *ConnGeneMap) UnmarshalJSON(bytes []byte) (err error) {
 
 }
 
+// XML has no representation for maps so the genes are written as a list
+func (im ConnGeneMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	genes := make([]*ConnGene, 0, len(im))
+	for _, m := range im.markers() {
+		genes = append(genes, im[m])
+	}
+	return e.EncodeElement(struct{ Gene []*ConnGene }{genes}, start)
+}
+
+func (im *ConnGeneMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	var genes struct{ Gene []*ConnGene }
+	err = d.DecodeElement(&genes, &start)
+	if err != nil {
+		return
+	}
+	*im = make(map[int]*ConnGene)
+	for _, g := range genes.Gene {
+		(*im)[g.Marker] = g
+	}
+	return
+}
+
+// Returns the markers of the connection genes in ascending order
+func (im ConnGeneMap) markers() []int {
+	ms := make([]int, 0, len(im))
+	for k := range im {
+		ms = append(ms, k)
+	}
+	sort.Ints(ms)
+	return ms
+}
+
 func (cg ConnGene) String() string {
 	var e string
 	if cg.Enabled {
@@ -203,8 +278,11 @@ func cloneGenome(source *Genome, id int) (clone *Genome) {
 	return clone
 }
 
-// Creates the initial genome to seed the population
-func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err error) {
+// Creates the initial genome to seed the population, with every bias and input
+// node connected to every output node by a zero weight. Each call takes new
+// markers from the tracker, so a population is seeded by cloning one genome.
+// The genome's ID is -1 until it is cloned.
+func NewMinimalGenome(settings *Settings, inno *Innovation) (genome *Genome, err error) {
 
 	// Shortcuts to settings values
 	biasCount := settings.BiasCount
@@ -223,13 +301,13 @@ func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err er
 		step = 1.0 / float64(biasCount+inputCount-1)
 	}
 	for i := 0; i < biasCount; i++ {
-		ng = &NodeGene{Marker: inno.nextMarker(), Type: neural.BIAS, X: step * float64(i), Y: 0}
+		ng = &NodeGene{Marker: inno.NextMarker(), Type: neural.BIAS, X: step * float64(i), Y: 0}
 		genome.Nodes[ng.Marker] = ng
 	}
 
 	// Create the input nodes
 	for i := 0; i < inputCount; i++ {
-		ng = &NodeGene{Marker: inno.nextMarker(), Type: neural.INPUT, X: step * float64(i+biasCount), Y: 0}
+		ng = &NodeGene{Marker: inno.NextMarker(), Type: neural.INPUT, X: step * float64(i+biasCount), Y: 0}
 		genome.Nodes[ng.Marker] = ng
 	}
 
@@ -239,15 +317,18 @@ func initialGenome(settings *Settings, inno *innovation) (genome *Genome, err er
 		step = 1.0 / float64(outputCount-1)
 	}
 	for i := 0; i < outputCount; i++ {
-		ng = &NodeGene{Marker: inno.nextMarker(), Type: neural.OUTPUT, X: step * float64(i), Y: 1.0}
+		ng = &NodeGene{Marker: inno.NextMarker(), Type: neural.OUTPUT, X: step * float64(i), Y: 1.0}
 		genome.Nodes[ng.Marker] = ng
 	}
 
 	// Create the connections
-	for _, in := range genome.Nodes {
-		for _, out := range genome.Nodes {
+	markers := genome.Nodes.markers()
+	for _, a := range markers {
+		in := genome.Nodes[a]
+		for _, b := range markers {
+			out := genome.Nodes[b]
 			if out.Type == neural.OUTPUT && (in.Type == neural.BIAS || in.Type == neural.INPUT) {
-				cg := &ConnGene{Marker: inno.nextMarker(),
+				cg := &ConnGene{Marker: inno.NextMarker(),
 					Enabled: true, Weight: 0, Source: in.Marker,
 					Target: out.Marker}
 				genome.Conns[cg.Marker] = cg
@@ -26,165 +26,260 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 package neat
 
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Node innovations are identified by the connection they split. Two genomes
+// splitting the same connection receive the same node.
 type nodeKey struct {
-	X, Y float64 // Position of the node in the network
+	Conn int // Marker of the connection which was split
 }
 
 type connKey struct {
 	Source, Target int // Markers of the source and target nodes
 }
 
-type nodeRequest struct {
-	key nodeKey
-	ret chan int
+// A remembered innovation
+type innovationEntry struct {
+	marker     int // Marker given to the innovation
+	generation int // Generation in which it first appeared
+}
+
+// Innovations is the state of the innovation tracker. It is archived with the
+// population so a restored run continues the same ID and marker sequences and
+// recognises structures which already exist in the lineage.
+type Innovations struct {
+	NextID     int              // Next ID to be handed out
+	NextMarker int              // Next marker to be handed out
+	Generation int              // Generation being bred when archived
+	Nodes      []NodeInnovation // History of node innovations
+	Conns      []ConnInnovation // History of connection innovations
 }
 
-type connRequest struct {
-	key connKey
-	ret chan int
+// A node gene innovation and the marker it was given
+type NodeInnovation struct {
+	Conn       int // Marker of the connection which was split
+	Marker     int
+	Generation int // Generation in which it first appeared
+}
+
+// A connection gene innovation and the marker it was given
+type ConnInnovation struct {
+	Source, Target int // Markers of the source and target nodes
+	Marker         int
+	Generation     int // Generation in which it first appeared
 }
 
-// Innovation provides new IDs and Markers to the different components
-// of the NEAT algorithm. The implementation here borrows from the good
-// work of others. Specifically, the "sequences" for IDs and Markers are
-// based on the AutoInc code found at
-//     http://www.mikespook.com/2012/05/golang-funny-play-with-channel/
-//
-// The maps used to "bless" node and connection genes are based on the
-// transactionMap found in David Chsinall's "The Go Programming Language
-// Phrasebook" in chapter 10, Concurrency Design Patterns.
-type innovation struct {
-	running bool // Flag indicating that innovation is still running
-
-	ids     chan int // queue of next available IDs
-	markers chan int // queue of next available markers
-
-	nodes map[nodeKey]int // history of node innovations
-	conns map[connKey]int // history of connection innovations
-
-	reqN chan nodeRequest
-	reqC chan connRequest
+// Innovation tracks the innovations of a run. It provides new IDs and markers
+// to the different components of the NEAT algorithm and remembers the markers
+// given to new structures so that the same structure arising more than once
+// is given the same marker. How long structures are remembered is set by
+// Settings.InnovationScope; a breeding loop calls Advance before each
+// generation and archives History with the population.
+// It is safe for concurrent use. Markers are handed out in the order genes
+// are blessed, so a run which blesses its genes in a fixed order receives the
+// same markers every time.
+type Innovation struct {
+	mu sync.Mutex
+
+	lastID     int // last ID handed out
+	lastMarker int // last marker handed out
+	generation int // generation currently being bred
+
+	nodes map[nodeKey]innovationEntry // history of node innovations
+	conns map[connKey]innovationEntry // history of connection innovations
 }
 
-func newInnovation(pop *Population) *innovation {
+// Creates the innovation tracker for the populations, any of which may be nil.
+// When several populations share a tracker their histories are merged.
+func NewInnovation(pops ...*Population) *Innovation {
 
 	// Create a new innovation
-	inno := &innovation{
-		running: true,
-		ids:     make(chan int, 8),
-		markers: make(chan int, 8),
-
-		reqN:  make(chan nodeRequest),
-		reqC:  make(chan connRequest),
-		nodes: make(map[nodeKey]int),
-		conns: make(map[connKey]int)}
-
-	// Identify and start the sequences
-	id := 0
-	marker := 0
-	if pop != nil {
-		for _, s := range pop.Species {
-			if s.ID > id {
-				id = s.ID
-			}
-			for _, o := range s.Orgs {
-				if o.ID > id {
-					id = o.ID
-				}
-				for _, g := range o.Nodes {
-					if g.Marker > marker {
-						marker = g.Marker
-					}
-				}
-				for _, g := range o.Conns {
-					if g.Marker > marker {
-						marker = g.Marker
-					}
-				}
-			}
+	inno := &Innovation{
+		nodes: make(map[nodeKey]innovationEntry),
+		conns: make(map[connKey]innovationEntry)}
+
+	for _, pop := range pops {
+		if pop != nil {
+			inno.merge(pop)
 		}
 	}
-	go inno.startIDs(id + 1)
-	go inno.startMarkers(marker + 1)
-
-	// Create and start the marker requests
-	inno.reqN = make(chan nodeRequest)
-	inno.reqC = make(chan connRequest)
-	go inno.runNodes()
-	go inno.runConns()
 
 	// Return the innovation
 	return inno
 
 }
 
-func (inno *innovation) close() {
-	inno.running = false
-	close(inno.ids)
-	close(inno.markers)
-	close(inno.reqC)
-	close(inno.reqN)
-}
+// Merges the population's innovations into the tracker. Populations archived
+// with their innovations continue where they left off, otherwise the sequences
+// start after the largest values in use.
+func (inno *Innovation) merge(pop *Population) {
 
-func (inno *innovation) startIDs(start int) {
-	defer func() { recover() }()
-	for i := start; inno.running; i++ {
-		inno.ids <- i
+	if pop.Innovations != nil {
+		h := pop.Innovations
+		if h.NextID-1 > inno.lastID {
+			inno.lastID = h.NextID - 1
+		}
+		if h.NextMarker-1 > inno.lastMarker {
+			inno.lastMarker = h.NextMarker - 1
+		}
+		if h.Generation > inno.generation {
+			inno.generation = h.Generation
+		}
+		for _, n := range h.Nodes {
+			inno.nodes[nodeKey{n.Conn}] = innovationEntry{n.Marker, n.Generation}
+		}
+		for _, c := range h.Conns {
+			inno.conns[connKey{c.Source, c.Target}] = innovationEntry{c.Marker, c.Generation}
+		}
+	} else {
+		if pop.Generation > inno.generation {
+			inno.generation = pop.Generation
+		}
+		for _, s := range pop.Species {
+			if s.ID > inno.lastID {
+				inno.lastID = s.ID
+			}
+		}
+		orgs := pop.Organisms()
+		if pop.Grid != nil {
+			orgs = append(orgs, pop.Grid.Elites()...)
+		}
+		for _, o := range orgs {
+			if o.ID > inno.lastID {
+				inno.lastID = o.ID
+			}
+			for _, g := range o.Nodes {
+				if g.Marker > inno.lastMarker {
+					inno.lastMarker = g.Marker
+				}
+			}
+			for _, g := range o.Conns {
+				if g.Marker > inno.lastMarker {
+					inno.lastMarker = g.Marker
+				}
+			}
+		}
 	}
 }
 
-func (inno *innovation) startMarkers(start int) {
-	defer func() { recover() }()
-	for i := start; inno.running; i++ {
-		inno.markers <- i
-	}
+// Returns the next ID for an organism or species
+func (inno *Innovation) NextID() int {
+	inno.mu.Lock()
+	defer inno.mu.Unlock()
+	inno.lastID += 1
+	return inno.lastID
 }
 
-func (inno *innovation) nextID() int {
-	return <-inno.ids
+// Returns a new marker for a gene which is not an innovation to be remembered
+func (inno *Innovation) NextMarker() int {
+	inno.mu.Lock()
+	defer inno.mu.Unlock()
+	inno.lastMarker += 1
+	return inno.lastMarker
 }
 
-func (inno *innovation) nextMarker() int {
-	return <-inno.markers
-}
+// Moves the tracker on to breeding the given generation, forgetting the
+// innovations which have fallen outside of the settings' innovation scope
+func (inno *Innovation) Advance(settings *Settings, generation int) (err error) {
 
-func (inno *innovation) reset() {
-	inno.nodes = make(map[nodeKey]int)
-	inno.conns = make(map[connKey]int)
-}
+	// Determine the oldest generation to remember
+	var oldest int
+	switch settings.InnovationScope {
+	case "", "generation":
+		oldest = generation
+	case "window":
+		if settings.InnovationWindow <= 0 {
+			err = fmt.Errorf("Innovation window of %d generations must be positive", settings.InnovationWindow)
+			return
+		}
+		oldest = generation - settings.InnovationWindow + 1
+	case "run":
+		oldest = 0
+	default:
+		err = fmt.Errorf("Unknown innovation scope %q", settings.InnovationScope)
+		return
+	}
 
-func (inno *innovation) runNodes() {
-	for inno.running {
-		req := <-inno.reqN
-		m, ok := inno.nodes[req.key]
-		if !ok {
-			m = <-inno.markers
-			inno.nodes[req.key] = m
+	inno.mu.Lock()
+	defer inno.mu.Unlock()
+	inno.generation = generation
+	for k, e := range inno.nodes {
+		if e.generation < oldest {
+			delete(inno.nodes, k)
+		}
+	}
+	for k, e := range inno.conns {
+		if e.generation < oldest {
+			delete(inno.conns, k)
 		}
-		req.ret <- m
 	}
+	return
 }
 
-func (inno *innovation) runConns() {
-	for inno.running {
-		req := <-inno.reqC
-		m, ok := inno.conns[req.key]
-		if !ok {
-			m = <-inno.markers
-			inno.conns[req.key] = m
-		}
-		req.ret <- m
+// Returns the marker for a node added by splitting the connection with the
+// marker splitConn. Nodes splitting the same connection within the innovation
+// scope receive the same marker.
+func (inno *Innovation) BlessNode(splitConn int) int {
+	key := nodeKey{splitConn}
+	inno.mu.Lock()
+	defer inno.mu.Unlock()
+	e, ok := inno.nodes[key]
+	if !ok {
+		inno.lastMarker += 1
+		e = innovationEntry{inno.lastMarker, inno.generation}
+		inno.nodes[key] = e
 	}
+	return e.marker
 }
 
-func (inno *innovation) blessNodeGene(key nodeKey) int {
-	result := make(chan int)
-	inno.reqN <- nodeRequest{key, result}
-	return <-result
+// Returns the marker for a connection from the node with the marker source to
+// the one with the marker target. Connections between the same nodes within
+// the innovation scope receive the same marker.
+func (inno *Innovation) BlessConn(source, target int) int {
+	key := connKey{source, target}
+	inno.mu.Lock()
+	defer inno.mu.Unlock()
+	e, ok := inno.conns[key]
+	if !ok {
+		inno.lastMarker += 1
+		e = innovationEntry{inno.lastMarker, inno.generation}
+		inno.conns[key] = e
+	}
+	return e.marker
 }
 
-func (inno *innovation) blessConnGene(key connKey) int {
-	result := make(chan int)
-	inno.reqC <- connRequest{key, result}
-	return <-result
+// Returns the current state of the tracker for archiving
+func (inno *Innovation) History() *Innovations {
+	inno.mu.Lock()
+	defer inno.mu.Unlock()
+	h := &Innovations{NextID: inno.lastID + 1, NextMarker: inno.lastMarker + 1,
+		Generation: inno.generation,
+		Nodes:      make([]NodeInnovation, 0, len(inno.nodes)),
+		Conns:      make([]ConnInnovation, 0, len(inno.conns))}
+	for k, e := range inno.nodes {
+		h.Nodes = append(h.Nodes, NodeInnovation{Conn: k.Conn, Marker: e.marker, Generation: e.generation})
+	}
+	for k, e := range inno.conns {
+		h.Conns = append(h.Conns, ConnInnovation{Source: k.Source, Target: k.Target,
+			Marker: e.marker, Generation: e.generation})
+	}
+	sort.Sort(nodeInnovations(h.Nodes))
+	sort.Sort(connInnovations(h.Conns))
+	return h
 }
+
+type nodeInnovations []NodeInnovation
+
+func (ni nodeInnovations) Len() int           { return len(ni) }
+func (ni nodeInnovations) Swap(i, j int)      { ni[i], ni[j] = ni[j], ni[i] }
+func (ni nodeInnovations) Less(i, j int) bool { return ni[i].Marker < ni[j].Marker }
+
+type connInnovations []ConnInnovation
+
+func (ci connInnovations) Len() int           { return len(ci) }
+func (ci connInnovations) Swap(i, j int)      { ci[i], ci[j] = ci[j], ci[i] }
+func (ci connInnovations) Less(i, j int) bool { return ci[i].Marker < ci[j].Marker }
@@ -0,0 +1,157 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"testing"
+)
+
+// Settings for a large population which adds structure quickly
+func benchSettings(size int) *Settings {
+	return &Settings{PopulationSize: size, BiasCount: 1, InputCount: 8, OutputCount: 4,
+		ExcessCoefficient: 1, DisjointCoefficient: 1, WeightCoefficient: 0.4,
+		MutateWeight: 0.8, MutateWeightNew: 0.1, MutateEnabled: 0.05,
+		MutateAddConnection: 0.3, MutateAddNode: 0.2,
+		Crossover: 0.75, InterspeciesMating: 0.001, AgeToStagnation: 15,
+		SurvivalPercent: 0.2, EliteCount: 1, CompatThreshold: 3, Seed: 1}
+}
+
+// Checks which innovations each scope remembers as the generations advance
+func TestInnovationScopes(t *testing.T) {
+	tests := []struct {
+		scope  string
+		window int
+		same   []bool // Whether the structure keeps its marker in generations 2 and 3
+	}{
+		{"generation", 0, []bool{false, false}},
+		{"window", 2, []bool{true, false}},
+		{"run", 0, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		settings := &Settings{InnovationScope: tt.scope, InnovationWindow: tt.window}
+		inno := NewInnovation(nil)
+		if err := inno.Advance(settings, 1); err != nil {
+			t.Fatal(err)
+		}
+		node := inno.BlessNode(7)
+		conn := inno.BlessConn(1, 2)
+		if inno.BlessNode(7) != node || inno.BlessConn(1, 2) != conn {
+			t.Errorf("Scope %q: a structure is given a new marker within its generation", tt.scope)
+		}
+		for i, same := range tt.same {
+			if err := inno.Advance(settings, i+2); err != nil {
+				t.Fatal(err)
+			}
+			n, c := inno.BlessNode(7), inno.BlessConn(1, 2)
+			if (n == node) != same || (c == conn) != same {
+				t.Errorf("Scope %q generation %d: got markers %d and %d after %d and %d, want reused %v",
+					tt.scope, i+2, n, c, node, conn, same)
+			}
+			node, conn = n, c
+		}
+	}
+}
+
+// Checks that a tracker created from an archived history continues its
+// sequences and remembers its innovations
+func TestInnovationHistory(t *testing.T) {
+	settings := &Settings{InnovationScope: "run"}
+	inno := NewInnovation(nil)
+	inno.Advance(settings, 1)
+	inno.NextID()
+	node := inno.BlessNode(3)
+	inno.Advance(settings, 2)
+	conn := inno.BlessConn(1, node)
+	h := inno.History()
+
+	resumed := NewInnovation(&Population{Generation: 2, Innovations: h})
+	resumed.Advance(settings, 3)
+	if id := resumed.NextID(); id != h.NextID {
+		t.Errorf("Got ID %d after resuming, want %d", id, h.NextID)
+	}
+	if m := resumed.NextMarker(); m != h.NextMarker {
+		t.Errorf("Got marker %d after resuming, want %d", m, h.NextMarker)
+	}
+	if m := resumed.BlessNode(3); m != node {
+		t.Errorf("Got node marker %d after resuming, want %d", m, node)
+	}
+	if m := resumed.BlessConn(1, node); m != conn {
+		t.Errorf("Got connection marker %d after resuming, want %d", m, conn)
+	}
+
+	// The generations in which they appeared are kept so the scope still
+	// forgets them on time
+	window := &Settings{InnovationScope: "window", InnovationWindow: 2}
+	resumed.Advance(window, 3)
+	if m := resumed.BlessNode(3); m == node {
+		t.Error("Node from generation 1 is remembered in generation 3 with a window of 2")
+	}
+	if m := resumed.BlessConn(1, node); m != conn {
+		t.Errorf("Got connection marker %d in generation 3, want %d from generation 2", m, conn)
+	}
+}
+
+// Blesses genes from many goroutines at once, as a concurrent breeder would
+func BenchmarkBlessParallel(b *testing.B) {
+	inno := NewInnovation(nil)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			inno.BlessNode(i % 5000)
+			inno.BlessConn(i%5000, i%3000)
+			i++
+		}
+	})
+}
+
+// Rolls a large population through one generation
+func benchmarkRollPop(b *testing.B, size int) {
+	settings := benchSettings(size)
+	inno := NewInnovation(nil)
+	random := NewRNG(settings.Seed)
+	pop, err := initialPopulation(settings, inno, random, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, o := range pop.Organisms() {
+			o.Fitness = []float64{random.Next()}
+		}
+		if err = score(settings, pop); err != nil {
+			b.Fatal(err)
+		}
+		pop, err = rollPop(settings, inno, random, pop, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "orgs/s")
+}
+
+func BenchmarkRollPop1000(b *testing.B)  { benchmarkRollPop(b, 1000) }
+func BenchmarkRollPop10000(b *testing.B) { benchmarkRollPop(b, 10000) }
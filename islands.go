@@ -0,0 +1,364 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Island is one of several populations evolved side by side by RunIslands.
+// Each island may have its own settings, archiver and reporter, though not its
+// own innovation scope.
+type Island struct {
+	Settings   *Settings   // Settings for this island. nil uses the shared settings
+	Archiver   Archiver    // Archives this island's population. May be nil
+	Reporter   Reporter    // Reports on this island's population. May be nil
+	Population *Population // The island's current population
+}
+
+// Topology decides where each island's migrants are sent
+type Topology interface {
+	// Returns the islands which receive migrants from island from, of n
+	Destinations(from, n int, random *rand.Rand) []int
+}
+
+type ring struct{}
+
+// Each island sends its migrants to the next, the last sending to the first
+func Ring() Topology {
+	return ring{}
+}
+
+func (t ring) Destinations(from, n int, random *rand.Rand) []int {
+	if n < 2 {
+		return nil
+	}
+	return []int{(from + 1) % n}
+}
+
+type fullyConnected struct{}
+
+// Each island sends its migrants to every other island
+func FullyConnected() Topology {
+	return fullyConnected{}
+}
+
+func (t fullyConnected) Destinations(from, n int, random *rand.Rand) []int {
+	dests := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if i != from {
+			dests = append(dests, i)
+		}
+	}
+	return dests
+}
+
+type randomTopology struct {
+	k int
+}
+
+// Each island sends its migrants to k other islands chosen at random at every
+// migration
+func RandomTopology(k int) Topology {
+	return randomTopology{k}
+}
+
+func (t randomTopology) Destinations(from, n int, random *rand.Rand) []int {
+	dests := make([]int, 0, n)
+	for _, i := range random.Perm(n) {
+		if i != from && len(dests) < t.k {
+			dests = append(dests, i)
+		}
+	}
+	return dests
+}
+
+// Runs several populations in parallel, migrating the best organisms between
+// them every Settings.MigrationInterval generations according to the
+// topology. The islands share one innovation tracker so their markers remain
+// consistent, and so must share its InnovationScope and InnovationWindow. Each island is restored from, archived to and reported on by its
+// own Archiver and Reporter, and its Population is numbered by its position
+// in islands, starting at 1.
+//
+// The islands are bred one after another, so runs remain reproducible, and are
+// decoded and evaluated in parallel. The stop condition sees every island's
+// organisms together and the stop reason is recorded in each population.
+// Errors and cancellation are handled as in Run, the islands' Population
+// fields holding the last fully evaluated populations.
+func RunIslands(ctx context.Context, settings *Settings, islands []*Island, topology Topology, stop StopCondition,
+	dcode Decoder, popEval PopEval, orgEval OrgEval, obs ...Observer) (err error) {
+
+	notify := observers(obs)
+	n := len(islands)
+
+	// Resolve each island's settings and complexity strategy
+	isettings := make([]*Settings, n)
+	strategies := make([]ComplexityStrategy, n)
+	for i, isl := range islands {
+		isettings[i] = isl.Settings
+		if isettings[i] == nil {
+			isettings[i] = settings
+		}
+		strategies[i], err = complexityStrategy(isettings[i])
+		if err == nil {
+			err = sameScope(settings, isettings[i])
+		}
+		if err != nil {
+			err = &RunError{Phase: PhaseInitialize, Err: err}
+			return
+		}
+	}
+
+	// Restore the populations
+	pops := make([]*Population, n)
+	for i, isl := range islands {
+		pops[i], err = restore(isettings[i], isl.Archiver)
+		if err != nil {
+			err = &RunError{Phase: PhaseRestore, Err: err}
+			return
+		}
+	}
+
+	// Create the shared innovation tracker and random number generator
+	inno := NewInnovation(pops...)
+	random := NewRNG(settings.Seed)
+
+	// Archive the last complete populations if the run is cut short
+	last := make([]*Population, n)
+	archived := make([]bool, n)
+	defer func() {
+		if err == nil || ctx.Err() == nil {
+			return
+		}
+		for i, isl := range islands {
+			if isl.Archiver != nil && last[i] != nil && !archived[i] {
+				last[i].Innovations = inno.History()
+				err = joinArchive(err, last[i].Generation, isl.Archiver.Archive(last[i]))
+			}
+		}
+	}()
+
+	prog := &Progress{Started: time.Now()}
+	for g := 0; ; g++ {
+
+		// Stop between generations if asked
+		if e := ctx.Err(); e != nil {
+			err = &RunError{Phase: PhaseRoll, Generation: generation(pops[0]), Err: e}
+			return
+		}
+
+		// Breed each island
+		for i := range islands {
+			notify.generationStart(generation(pops[i]) + 1)
+			pops[i], err = breed(isettings[i], strategies[i], inno, random, pops[i], notify)
+			if err != nil {
+				return
+			}
+			pops[i].Island = i + 1
+		}
+
+		// Decode and evaluate the islands in parallel
+		errs := make([]error, n)
+		var w sync.WaitGroup
+		for i := range islands {
+			w.Add(1)
+			go func(i int) {
+				defer w.Done()
+				e := decode(ctx, pops[i], dcode)
+				if e != nil {
+					errs[i] = &RunError{Phase: PhaseDecode, Generation: pops[i].Generation, Err: e}
+					return
+				}
+				e = evaluate(ctx, isettings[i], pops[i], popEval, orgEval)
+				if e != nil {
+					errs[i] = &RunError{Phase: PhaseEvaluate, Generation: pops[i].Generation, Err: e}
+				}
+			}(i)
+		}
+		w.Wait()
+		for _, e := range errs {
+			if e != nil {
+				err = e
+				return
+			}
+		}
+		for i, isl := range islands {
+			isl.Population = pops[i]
+			last[i] = pops[i]
+			archived[i] = false
+			notify.decoded(pops[i])
+			notify.evaluated(pops[i])
+		}
+
+		// Check the stop condition against every island together
+		all := &Population{Generation: pops[0].Generation}
+		for _, pop := range pops {
+			all.Species = append(all.Species, pop.Species...)
+		}
+		done := false
+		if champ := prog.update(all); champ != nil {
+			notify.championImproved(all, champ)
+		}
+		reason := ""
+		if stop != nil {
+			done, reason = stop.Stop(all, prog)
+			if !done {
+				reason = ""
+			}
+		}
+
+		// Migrate the best organisms between the islands
+		if !done && settings.MigrationInterval > 0 && (g+1)%settings.MigrationInterval == 0 {
+			err = migrate(settings, isettings, topology, inno, random, pops, notify)
+			if err != nil {
+				err = &RunError{Phase: PhaseRoll, Generation: pops[0].Generation, Err: err}
+				return
+			}
+		}
+
+		// Archive and report each island
+		for i, isl := range islands {
+			pops[i].StopReason = reason
+			if isl.Archiver != nil && (done ||
+				(isettings[i].ArchiveFrequency == 0 || g%isettings[i].ArchiveFrequency == 0)) {
+				pops[i].Innovations = inno.History()
+				err = isl.Archiver.Archive(pops[i])
+				if err != nil {
+					err = &RunError{Phase: PhaseArchive, Generation: pops[i].Generation, Err: err}
+					return
+				}
+				archived[i] = true
+			}
+			if isl.Reporter != nil && (done ||
+				(isettings[i].ReportFrequency == 0 || g%isettings[i].ReportFrequency == 0)) {
+				err = isl.Reporter.Report(pops[i])
+				if err != nil {
+					err = &RunError{Phase: PhaseReport, Generation: pops[i].Generation, Err: err}
+					return
+				}
+			}
+		}
+
+		if done {
+			return
+		}
+	}
+}
+
+// Returns an error if an island's settings give the shared innovation tracker
+// a different scope than the shared settings
+func sameScope(settings, island *Settings) (err error) {
+	scope := func(s *Settings) string {
+		if s.InnovationScope == "" {
+			return "generation"
+		}
+		return s.InnovationScope
+	}
+	if scope(island) != scope(settings) ||
+		(scope(settings) == "window" && island.InnovationWindow != settings.InnovationWindow) {
+		err = fmt.Errorf("Island innovation scope %q (window %d) differs from the shared %q (window %d)",
+			island.InnovationScope, island.InnovationWindow, settings.InnovationScope, settings.InnovationWindow)
+	}
+	return
+}
+
+// Sends copies of each island's best organisms to its destinations, where
+// they replace the worst organisms and are placed into species. The receiving
+// islands are scored again.
+func migrate(settings *Settings, isettings []*Settings, topology Topology, inno *Innovation, random *RNG,
+	pops []*Population, notify observers) (err error) {
+
+	// Choose the emigrants before any island is changed
+	n := len(pops)
+	arrivals := make([][]*Organism, n)
+	for i, pop := range pops {
+		orgs := pop.Organisms()
+		sort.Stable(sort.Reverse(orgs))
+		cnt := settings.MigrationCount
+		if cnt > len(orgs) {
+			cnt = len(orgs)
+		}
+		for _, d := range topology.Destinations(i, n, random.Rand) {
+			for _, o := range orgs[:cnt] {
+				m := Clone(inno, o)
+				m.Fitness = append([]float64(nil), o.Fitness...)
+				m.Behavior = append([]float64(nil), o.Behavior...)
+				arrivals[d] = append(arrivals[d], m)
+			}
+		}
+	}
+
+	// Settle the migrants
+	for d, migrants := range arrivals {
+		if len(migrants) == 0 {
+			continue
+		}
+		pop := pops[d]
+
+		// Make room by removing the worst organisms
+		orgs := pop.Organisms()
+		sort.Stable(orgs)
+		leaving := make(map[*Organism]bool)
+		for i := 0; i < len(migrants) && i < len(orgs); i++ {
+			leaving[orgs[i]] = true
+		}
+		for _, s := range pop.Species {
+			staying := make([]*Organism, 0, len(s.Orgs))
+			for _, o := range s.Orgs {
+				if !leaving[o] {
+					staying = append(staying, o)
+				}
+			}
+			s.Orgs = staying
+			if leaving[s.Example] && len(s.Orgs) > 0 {
+				s.Example = s.Orgs[0]
+			}
+		}
+
+		// Place the migrants into species and drop any left empty
+		speciate(isettings[d], inno, pop, migrants, notify)
+		living := make([]*Species, 0, len(pop.Species))
+		for _, s := range pop.Species {
+			if len(s.Orgs) > 0 {
+				living = append(living, s)
+			} else {
+				notify.speciesExtinct(pop, s)
+			}
+		}
+		pop.Species = living
+		err = score(isettings[d], pop)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
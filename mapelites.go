@@ -0,0 +1,327 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Grid is the archive of elites kept by MAP-Elites (Mouret and Clune, 2015).
+// The space of behaviour descriptors is divided into bins along each
+// dimension and each cell keeps the fittest organism whose behaviour fell in
+// it. Only occupied cells are stored, in order of their keys.
+type Grid struct {
+	Bins  []int     // Number of bins along each dimension
+	Min   []float64 // Lower bound of each dimension
+	Max   []float64 // Upper bound of each dimension
+	Cells []Cell    // The occupied cells
+}
+
+// A cell of the grid and its elite
+type Cell struct {
+	Key   int       // Position of the cell with the dimensions flattened
+	Index []int     // Bin of the cell along each dimension
+	Elite *Organism // Fittest organism found in the cell
+}
+
+// Creates an empty grid from Settings.GridBins, GridMin and GridMax
+func NewGrid(settings *Settings) (grid *Grid, err error) {
+	n := len(settings.GridBins)
+	if n == 0 || len(settings.GridMin) != n || len(settings.GridMax) != n {
+		err = errors.New("GridBins, GridMin and GridMax must have the same, non-zero, length")
+		return
+	}
+	for i, b := range settings.GridBins {
+		if b < 1 || settings.GridMax[i] <= settings.GridMin[i] {
+			err = fmt.Errorf("Grid dimension %d is empty", i)
+			return
+		}
+	}
+	grid = &Grid{
+		Bins: append([]int(nil), settings.GridBins...),
+		Min:  append([]float64(nil), settings.GridMin...),
+		Max:  append([]float64(nil), settings.GridMax...)}
+	return
+}
+
+// Places the organism into the cell of its behaviour if the cell is empty or
+// the organism is fitter than the cell's elite. Behaviours outside the bounds
+// fall into the nearest cell.
+func (grid *Grid) Place(org *Organism) (placed bool, err error) {
+
+	if len(org.Behavior) != len(grid.Bins) {
+		err = fmt.Errorf("Organism %d has a behaviour of %d dimensions for a grid of %d",
+			org.ID, len(org.Behavior), len(grid.Bins))
+		return
+	}
+
+	// Find the cell
+	key := 0
+	index := make([]int, len(grid.Bins))
+	for i, v := range org.Behavior {
+		b := int((v - grid.Min[i]) / (grid.Max[i] - grid.Min[i]) * float64(grid.Bins[i]))
+		if b < 0 {
+			b = 0
+		}
+		if b >= grid.Bins[i] {
+			b = grid.Bins[i] - 1
+		}
+		index[i] = b
+		key = key*grid.Bins[i] + b
+	}
+
+	// Keep the fitter organism
+	c := sort.Search(len(grid.Cells), func(i int) bool { return grid.Cells[i].Key >= key })
+	if c < len(grid.Cells) && grid.Cells[c].Key == key {
+		if fitness(org) > fitness(grid.Cells[c].Elite) {
+			grid.Cells[c].Elite = org
+			placed = true
+		}
+		return
+	}
+	grid.Cells = append(grid.Cells, Cell{})
+	copy(grid.Cells[c+1:], grid.Cells[c:])
+	grid.Cells[c] = Cell{Key: key, Index: index, Elite: org}
+	placed = true
+	return
+}
+
+// Returns the elite of every occupied cell
+func (grid *Grid) Elites() OrganismSlice {
+	orgs := make(OrganismSlice, len(grid.Cells))
+	for i, c := range grid.Cells {
+		orgs[i] = c.Elite
+	}
+	return orgs
+}
+
+// Returns the fraction of cells which are occupied
+func (grid *Grid) Coverage() float64 {
+	n := 1
+	for _, b := range grid.Bins {
+		n *= b
+	}
+	return float64(len(grid.Cells)) / float64(n)
+}
+
+// Returns the quality-diversity score, the sum of the elites' fitness
+func (grid *Grid) QDScore() float64 {
+	sum := float64(0)
+	for _, c := range grid.Cells {
+		sum += fitness(c.Elite)
+	}
+	return sum
+}
+
+// Returns the first fitness value of the organism, or 0 if it has none
+func fitness(org *Organism) float64 {
+	if len(org.Fitness) == 0 {
+		return 0
+	}
+	return org.Fitness[0]
+}
+
+// Runs MAP-Elites until the stop condition is met. Each generation
+// Settings.PopulationSize offspring are bred from elites chosen at random from
+// the grid, using NEAT's crossover and mutation, and are placed into the grid
+// by the behaviour descriptors returned by orgEval, which must be a
+// BehaviorEval. The grid is kept in Population.Grid, so it is archived with the
+// population, while the population's single species holds the generation's
+// offspring. Errors and cancellation are handled as in Run.
+func RunMAPElites(ctx context.Context, settings *Settings, stop StopCondition, dcode Decoder, popEval PopEval,
+	orgEval OrgEval, arch Archiver, rep Reporter, obs ...Observer) (last *Population, err error) {
+
+	notify := observers(obs)
+	if _, ok := orgEval.(BehaviorEval); !ok {
+		err = &RunError{Phase: PhaseInitialize, Err: errors.New("MAP-Elites needs a BehaviorEval")}
+		return
+	}
+
+	// Restore the population and ensure the grid
+	population, err := restore(settings, arch)
+	if err != nil {
+		err = &RunError{Phase: PhaseRestore, Err: err}
+		return
+	}
+	var grid *Grid
+	if population != nil {
+		grid = population.Grid
+	}
+	if grid == nil {
+		grid, err = NewGrid(settings)
+		if err != nil {
+			err = &RunError{Phase: PhaseInitialize, Err: err}
+			return
+		}
+	}
+
+	// Create the innovation tracker and random number generator
+	inno := NewInnovation(population)
+	random := NewRNG(settings.Seed)
+
+	// Archive the last complete population if the run is cut short
+	archived := true
+	defer func() {
+		if err != nil && ctx.Err() != nil && arch != nil && last != nil && !archived {
+			last.Innovations = inno.History()
+			err = joinArchive(err, last.Generation, arch.Archive(last))
+		}
+	}()
+
+	prog := &Progress{Started: time.Now()}
+	for i := 0; ; i++ {
+
+		// Stop between generations if asked
+		if e := ctx.Err(); e != nil {
+			err = &RunError{Phase: PhaseRoll, Generation: generation(population), Err: e}
+			return
+		}
+		notify.generationStart(generation(population) + 1)
+
+		// Breed the offspring
+		population, err = breedElites(settings, inno, random, grid, population, notify)
+		if err != nil {
+			err = &RunError{Phase: PhaseRoll, Generation: generation(population) + 1, Err: err}
+			return
+		}
+
+		// Decode and evaluate the offspring
+		err = decode(ctx, population, dcode)
+		if err != nil {
+			err = &RunError{Phase: PhaseDecode, Generation: population.Generation, Err: err}
+			return
+		}
+		notify.decoded(population)
+		err = evaluate(ctx, settings, population, popEval, orgEval)
+		if err != nil {
+			err = &RunError{Phase: PhaseEvaluate, Generation: population.Generation, Err: err}
+			return
+		}
+
+		// Place them into the grid
+		for _, o := range population.Organisms() {
+			_, err = grid.Place(o)
+			if err != nil {
+				err = &RunError{Phase: PhaseEvaluate, Generation: population.Generation, Err: err}
+				return
+			}
+		}
+		last = population
+		archived = false
+		notify.evaluated(population)
+
+		// Check the stop condition
+		done := false
+		if champ := prog.update(population); champ != nil {
+			notify.championImproved(population, champ)
+		}
+		if stop != nil {
+			done, population.StopReason = stop.Stop(population, prog)
+			if !done {
+				population.StopReason = ""
+			}
+		}
+
+		// Archive the population
+		if arch != nil && (done ||
+			(settings.ArchiveFrequency == 0 || i%settings.ArchiveFrequency == 0)) {
+			population.Innovations = inno.History()
+			err = arch.Archive(population)
+			if err != nil {
+				err = &RunError{Phase: PhaseArchive, Generation: population.Generation, Err: err}
+				return
+			}
+			archived = true
+		}
+
+		// Report the population
+		if rep != nil && (done || (settings.ReportFrequency == 0 || i%settings.ReportFrequency == 0)) {
+			err = rep.Report(population)
+			if err != nil {
+				err = &RunError{Phase: PhaseReport, Generation: population.Generation, Err: err}
+				return
+			}
+		}
+
+		if done {
+			return
+		}
+	}
+}
+
+// Breeds the next generation of offspring from the grid's elites. While the
+// grid is empty the initial population is used.
+func breedElites(settings *Settings, inno *Innovation, random *RNG, grid *Grid, pop *Population,
+	notify observers) (next *Population, err error) {
+
+	if len(grid.Cells) == 0 {
+		next, err = initialPopulation(settings, inno, random, notify)
+		if err == nil {
+			next.Generation = generation(pop) + 1
+			next.Grid = grid
+		}
+		return
+	}
+
+	// Keep the offspring in a single species
+	s := &Species{Orgs: make([]*Organism, 0, settings.PopulationSize)}
+	if pop != nil && len(pop.Species) > 0 {
+		s.ID, s.Age = pop.Species[0].ID, pop.Species[0].Age+1
+	} else {
+		s.ID = inno.NextID()
+	}
+	next = &Population{Generation: generation(pop) + 1, Species: []*Species{s}, Grid: grid}
+	err = inno.Advance(settings, next.Generation)
+	if err == nil {
+		err = checkMutationRates(settings)
+	}
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < settings.PopulationSize; i++ {
+		p1 := grid.Cells[random.Int(len(grid.Cells))].Elite
+		var child *Organism
+		if len(grid.Cells) > 1 && random.Next() < settings.Crossover {
+			p2 := grid.Cells[random.Int(len(grid.Cells))].Elite
+			child = Crossover(inno, random, p1, p2)
+		} else {
+			child = Clone(inno, p1)
+		}
+		Mutate(settings, inno, random, child)
+		if err = debugCheck(settings, child); err != nil {
+			return
+		}
+		s.Orgs = append(s.Orgs, child)
+	}
+	s.Example = s.Orgs[0]
+	return
+}
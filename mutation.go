@@ -0,0 +1,184 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+)
+
+// MutationOperator makes one kind of change to an organism's genome. New
+// structures are given their markers by the innovation tracker's BlessNode and
+// BlessConn so that matching innovations in the same scope share them.
+type MutationOperator interface {
+	Mutate(settings *Settings, inno *Innovation, random *RNG, org *Organism)
+}
+
+// MutationFunc adapts a function to a MutationOperator
+type MutationFunc func(settings *Settings, inno *Innovation, random *RNG, org *Organism)
+
+func (f MutationFunc) Mutate(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+	f(settings, inno, random, org)
+}
+
+// The probability of the named operator being applied to a child
+type MutationRate struct {
+	Operator string
+	Rate     float64
+}
+
+// A registered mutation operator
+type mutation struct {
+	name    string
+	op      MutationOperator
+	perGene bool // Applies its own rates to each gene instead of a rate per child
+}
+
+// The registered operators in the order they are applied. Kept in a slice so
+// that runs with the same seed make the same mutations.
+var mutations = []mutation{
+	{"add-node", MutationFunc(mutateAddNode), false},
+	{"add-connection", MutationFunc(mutateAddConn), false},
+	{"delete-node", MutationFunc(mutateDelNode), false},
+	{"delete-connection", MutationFunc(mutateDelConnection), false},
+	{"perturb-weights", MutationFunc(perturbWeights), true},
+	{"replace-weights", MutationFunc(replaceWeights), true},
+	{"re-enable", MutationFunc(reenableConns), true},
+	{"toggle-enable", MutationFunc(toggleEnabled), false},
+	{"change-activation", MutationFunc(changeActivations), true},
+}
+
+// Registers a mutation operator under the name, which is given its rate per
+// child through Settings.MutationRates. Registering one of the built in names
+// replaces that operator but keeps its rate. Registration should happen during
+// program initialization.
+func RegisterMutation(name string, op MutationOperator) {
+	for i := range mutations {
+		if mutations[i].name == name {
+			mutations[i].op = op
+			return
+		}
+	}
+	mutations = append(mutations, mutation{name: name, op: op})
+}
+
+// Returns the probability of the named operator being applied to a child.
+// Settings.MutationRates takes precedence over the operator's own field, and
+// operators working gene by gene are otherwise applied to every child.
+func mutationRate(settings *Settings, m mutation) float64 {
+	for _, r := range settings.MutationRates {
+		if r.Operator == m.name {
+			return r.Rate
+		}
+	}
+	if m.perGene {
+		return 1
+	}
+	switch m.name {
+	case "add-node":
+		return settings.MutateAddNode
+	case "add-connection":
+		return settings.MutateAddConnection
+	case "delete-node":
+		return settings.MutateDelNode
+	case "delete-connection":
+		return settings.MutateDelConnection
+	}
+	return 0
+}
+
+// Returns an error if Settings.MutationRates names an operator which is not
+// registered
+func checkMutationRates(settings *Settings) (err error) {
+	for _, r := range settings.MutationRates {
+		found := false
+		for _, m := range mutations {
+			if m.name == r.Operator {
+				found = true
+			}
+		}
+		if !found {
+			err = fmt.Errorf("Unknown mutation operator %q", r.Operator)
+			return
+		}
+	}
+	return
+}
+
+// Mutates the organism's genome in place with the registered operators. By
+// default a child receives at most one of the structural operators, such as
+// adding a node, chosen in proportion to their rates, and only a child which
+// receives none is passed to the operators working gene by gene, such as
+// perturbing weights, each applied with its own rate. With
+// Settings.MultipleMutations every operator is applied with its rate
+// independently of the others, the gene by gene ones last.
+func Mutate(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+
+	// Apply the operators with a rate per child
+	mutated := false
+	if settings.MultipleMutations {
+		for _, m := range mutations {
+			if !m.perGene && random.Next() < mutationRate(settings, m) {
+				m.op.Mutate(settings, inno, random, org)
+				mutated = true
+			}
+		}
+	} else {
+
+		// Rates which sum to more than 1 are scaled down to fit
+		total := float64(0)
+		for _, m := range mutations {
+			if !m.perGene {
+				total += mutationRate(settings, m)
+			}
+		}
+		r := random.Next() * math.Max(total, 1)
+		for _, m := range mutations {
+			if m.perGene {
+				continue
+			}
+			r -= mutationRate(settings, m)
+			if r < 0 {
+				m.op.Mutate(settings, inno, random, org)
+				mutated = true
+				break
+			}
+		}
+	}
+
+	// Apply the operators which work gene by gene
+	if !mutated || settings.MultipleMutations {
+		for _, m := range mutations {
+			if !m.perGene {
+				continue
+			}
+			if rate := mutationRate(settings, m); rate >= 1 || random.Next() < rate {
+				m.op.Mutate(settings, inno, random, org)
+			}
+		}
+	}
+}
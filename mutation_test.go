@@ -0,0 +1,127 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"testing"
+)
+
+// Registers an operator for the duration of a test
+func registerTestMutation(t *testing.T, name string, op MutationOperator) {
+	saved := append([]mutation(nil), mutations...)
+	t.Cleanup(func() { mutations = saved })
+	RegisterMutation(name, op)
+}
+
+// Checks that a registered operator can add a connection whose marker is
+// shared by every child making the same innovation
+func TestRegisterMutationAddsConnection(t *testing.T) {
+	registerTestMutation(t, "bias-to-output", MutationFunc(
+		func(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+			cg := &ConnGene{Source: 1, Target: 4, Enabled: true, Weight: random.Gaussian()}
+			cg.Marker = inno.BlessConn(cg.Source, cg.Target)
+			org.Conns[cg.Marker] = cg
+		}))
+	settings := &Settings{MutationRates: []MutationRate{{"bias-to-output", 1}}}
+	if err := checkMutationRates(settings); err != nil {
+		t.Fatal(err)
+	}
+	inno := NewInnovation(nil)
+	random := NewRNG(1)
+	marker := 0
+	for i := 0; i < 3; i++ {
+		org := testParent(i+1, "", [3]int{10, 2, 4})
+		Mutate(settings, inno, random, org)
+		if len(org.Conns) != 2 {
+			t.Fatalf("Organism %d has %d connections, want 2", org.ID, len(org.Conns))
+		}
+		for m, cg := range org.Conns {
+			if cg.Source == 1 && cg.Target == 4 {
+				if marker != 0 && m != marker {
+					t.Errorf("Organism %d has marker %d for the new connection, want %d", org.ID, m, marker)
+				}
+				marker = m
+			}
+		}
+	}
+	if marker == 0 {
+		t.Fatal("No connection was added")
+	}
+}
+
+// Checks that the gene by gene operators take their rates from MutationRates
+func TestMutationRatesPerGene(t *testing.T) {
+	for _, rate := range []float64{0, 1} {
+		settings := &Settings{MutateWeight: 1, MutationRates: []MutationRate{
+			{"perturb-weights", rate}, {"replace-weights", rate}}}
+		org := testParent(1, "", [3]int{10, 2, 4})
+		Mutate(settings, NewInnovation(nil), NewRNG(1), org)
+		if changed := org.Conns[10].Weight != 1; changed != (rate == 1) {
+			t.Errorf("With rate %v the weight changed to %v", rate, org.Conns[10].Weight)
+		}
+	}
+}
+
+// Checks that toggling flips connections without cutting off their targets
+func TestToggleEnabled(t *testing.T) {
+	settings := &Settings{MutationRates: []MutationRate{{"toggle-enable", 1}}}
+	inno := NewInnovation(nil)
+	random := NewRNG(1)
+
+	// A lone connection into a node stays enabled
+	org := testParent(1, "", [3]int{10, 2, 4})
+	for i := 0; i < 10; i++ {
+		Mutate(settings, inno, random, org)
+	}
+	if !org.Conns[10].Enabled {
+		t.Error("The only connection into the output was disabled")
+	}
+
+	// Of two, one is disabled and then re-enabled
+	org = testParent(2, "", [3]int{10, 2, 4}, [3]int{11, 3, 4})
+	Mutate(settings, inno, random, org)
+	if org.Conns[10].Enabled == org.Conns[11].Enabled {
+		t.Fatalf("Got enabled states %v and %v, want one disabled", org.Conns[10].Enabled, org.Conns[11].Enabled)
+	}
+	for i := 0; i < 20 && !(org.Conns[10].Enabled && org.Conns[11].Enabled); i++ {
+		Mutate(settings, inno, random, org)
+		if !org.Conns[10].Enabled && !org.Conns[11].Enabled {
+			t.Fatal("Both connections into the output were disabled")
+		}
+	}
+	if !org.Conns[10].Enabled || !org.Conns[11].Enabled {
+		t.Error("The disabled connection was never re-enabled")
+	}
+}
+
+// Checks that naming an unregistered operator is an error
+func TestCheckMutationRates(t *testing.T) {
+	settings := &Settings{MutationRates: []MutationRate{{"no-such-operator", 0.5}}}
+	if err := checkMutationRates(settings); err == nil {
+		t.Error("Unknown operator was accepted")
+	}
+}
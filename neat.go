@@ -27,8 +27,10 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package neat
 
 import (
-	"fmt"
+	"context"
+	"os"
 	"sync"
+	"time"
 )
 
 type Decoder interface {
@@ -39,120 +41,285 @@ type OrgEval interface {
 	Evaluate(org *Organism) (err error)
 }
 
+// BehaviorEval is implemented by organism evaluators which also characterise
+// the behaviour of the organism for novelty search. EvaluateBehavior sets the
+// organism's fitness as Evaluate does and returns its behaviour, which the run
+// records in Organism.Behavior.
+type BehaviorEval interface {
+	OrgEval
+	EvaluateBehavior(org *Organism) (behavior []float64, err error)
+}
+
 type PopEval interface {
 	Evaluate(pop *Population, orgEval OrgEval) (err error)
 }
 
+// ContextPopEval is implemented by population evaluators which can stop
+// part way through a generation when the context is cancelled.
+type ContextPopEval interface {
+	PopEval
+	EvaluateContext(ctx context.Context, pop *Population, orgEval OrgEval) (err error)
+}
+
+// Iterates the population for n generations. Nothing is run when n is not
+// positive. Any failure causes a panic. Use Run for a cancellable version which
+// returns its errors.
 func Iterate(settings *Settings, n int, dcode Decoder, popEval PopEval, orgEval OrgEval, arch Archiver, rep Reporter) {
+	if n <= 0 {
+		return
+	}
+	_, err := Run(context.Background(), settings, MaxGenerations(n), dcode, popEval, orgEval, arch, rep)
+	if err != nil {
+		panic(err)
+	}
+}
 
-	var err error
+// Runs the population until the stop condition is met, restoring it from and
+// archiving it to arch if one is provided. A nil stop condition runs until ctx
+// is cancelled. Any observers are notified as each generation progresses.
+//
+// Run returns the last fully evaluated population, whose StopReason notes why
+// the run ended. Failures are returned as a *RunError noting the phase which
+// failed. If ctx is cancelled the run stops at the next opportunity, the last
+// fully evaluated population is archived and a *RunError wrapping ctx.Err()
+// is returned, joined with any error from that archiving.
+func Run(ctx context.Context, settings *Settings, stop StopCondition, dcode Decoder, popEval PopEval,
+	orgEval OrgEval, arch Archiver, rep Reporter, obs ...Observer) (last *Population, err error) {
 
-	// Phase search parameters
-	var pth float64 // Pruning threshold
-	var cmplx bool  // Switch between complexifying (true) and simplifying (false)
-	var addNode, delNode, addConn, delConn, cross float64 // original values
-	addNode = settings.MutateAddNode
-	delNode = settings.MutateDelNode
-	addConn = settings.MutateAddConnection
-	delConn = settings.MutateDelConnection
-	cross = settings.Crossover
-	cmplx = true	// Start with complexifying
+	notify := observers(obs)
+
+	// Look up the complexity strategy
+	strategy, err := complexityStrategy(settings)
+	if err != nil {
+		err = &RunError{Phase: PhaseInitialize, Err: err}
+		return
+	}
 
 	// Restore the population
-	var population *Population
-	if arch != nil {
-		population, err = arch.Restore()
-		if err != nil {
-			fmt.Println("Restore failed:", err) // Will begin a new population
-		} else {
-			pth = population.MPC() + settings.PruneThreshold
-		}
+	population, err := restore(settings, arch)
+	if err != nil {
+		err = &RunError{Phase: PhaseRestore, Err: err}
+		return
 	}
 
-	// Create the innovation tracker
-	inno := newInnovation(population)
-	defer inno.close()
+	// Create the innovation tracker and random number generator
+	inno := NewInnovation(population)
+	random := NewRNG(settings.Seed)
+
+	// Archive the last complete population if the run is cut short
+	archived := true
+	defer func() {
+		if err != nil && ctx.Err() != nil && arch != nil && last != nil && !archived {
+			last.Innovations = inno.History()
+			err = joinArchive(err, last.Generation, arch.Archive(last))
+		}
+	}()
 
 	//Iterate
-	for i := 0; i < n; i++ {
+	prog := &Progress{Started: time.Now()}
+	for i := 0; ; i++ {
 
-		// Ensure the current population
-		if population == nil {
-			population, err = initialPopulation(settings, inno)
-			pth = population.MPC() + settings.PruneThreshold
-		} else {
-
-			// Determine if the search should switch to decomplexifying
-			if cmplx {}
-			mpc := population.MPC()
-			cmplx = (settings.PruneThreshold > 0) && (mpc < pth)
-			} else {
-				m = population.MPC()
-				if nochg > settings.PruneFloor {
-					cmplx = true
-				}
-				
-			}
-			if cmplx {
-				settings.MutateAddNode = addNode
-				settings.MutateAddConnection = addConn
-				settings.MutateDelNode = 0
-				settings.MutateDelConnection = 0
-				settings.Crossover = cross
-			} else {
-				settings.MutateAddNode = 0
-				settings.MutateAddConnection = 0
-				settings.MutateDelNode = delNode
-				settings.MutateDelConnection = delConn
-				settings.Crossover = 0
-			}
-			// Roll to the next generation
-			population, err = rollPop(settings, inno, population)
+		// Stop between generations if asked
+		if e := ctx.Err(); e != nil {
+			err = &RunError{Phase: PhaseRoll, Generation: generation(population), Err: e}
+			return
 		}
+		notify.generationStart(generation(population) + 1)
+
+		// Ensure the current population
+		population, err = breed(settings, strategy, inno, random, population, notify)
 		if err != nil {
-			panic(err)
+			return
 		}
 
 		// Ensure every organism is decoded
-		var w sync.WaitGroup
-		orgs := population.Species.Organisms(settings)
-		for _, o := range orgs {
-			if o.Phenome == nil {
-				w.Add(1)
-				go func(o *Organism) {
-					o.Phenome, err = dcode.Decode(o.Genome)
-					if err != nil {
-						// Do what exactly?
-					}
-					w.Done()
-				}(o)
-			}
+		err = decode(ctx, population, dcode)
+		if err != nil {
+			err = &RunError{Phase: PhaseDecode, Generation: population.Generation, Err: err}
+			return
 		}
-		w.Wait()
+		notify.decoded(population)
 
 		// Evaluate each organism
-		err = popEval.Evaluate(population, orgEval)
+		err = evaluate(ctx, settings, population, popEval, orgEval)
 		if err != nil {
-			panic(err)
+			err = &RunError{Phase: PhaseEvaluate, Generation: population.Generation, Err: err}
+			return
+		}
+		last = population
+		archived = false
+		notify.evaluated(population)
+
+		// Check the stop condition
+		done := false
+		if champ := prog.update(population); champ != nil {
+			notify.championImproved(population, champ)
+		}
+		if stop != nil {
+			done, population.StopReason = stop.Stop(population, prog)
+			if !done {
+				population.StopReason = ""
+			}
 		}
 
 		// Archive the population
-		if arch != nil && (i == n-1 ||
+		if arch != nil && (done ||
 			(settings.ArchiveFrequency == 0 || i%settings.ArchiveFrequency == 0)) {
+			population.Innovations = inno.History()
 			err = arch.Archive(population)
 			if err != nil {
-				panic(err)
+				err = &RunError{Phase: PhaseArchive, Generation: population.Generation, Err: err}
+				return
 			}
+			archived = true
 		}
 
 		// Report the population
-		if rep != nil && (i == n-1 || (settings.ReportFrequency == 0 || i%settings.ReportFrequency == 0)) {
+		if rep != nil && (done || (settings.ReportFrequency == 0 || i%settings.ReportFrequency == 0)) {
 			err = rep.Report(population)
 			if err != nil {
-				panic(err)
+				err = &RunError{Phase: PhaseReport, Generation: population.Generation, Err: err}
+				return
 			}
 		}
 
+		if done {
+			return
+		}
+	}
+}
+
+// Creates the initial population if there is none, otherwise rolls it to the
+// next generation under the complexity strategy. Errors are returned as a
+// *RunError.
+func breed(settings *Settings, strategy ComplexityStrategy, inno *Innovation, random *RNG,
+	pop *Population, notify observers) (next *Population, err error) {
+
+	if pop == nil {
+		next, err = initialPopulation(settings, inno, random, notify)
+		if err != nil {
+			err = &RunError{Phase: PhaseInitialize, Err: err}
+		}
+		return
 	}
 
+	// Determine the settings for breeding under the complexity strategy
+	was := pop.Complexity.Simplifying
+	effective := strategy.Adjust(settings, pop)
+	if pop.Complexity.Simplifying != was {
+		notify.phaseSwitched(pop, !pop.Complexity.Simplifying)
+	}
+
+	// Roll to the next generation
+	next, err = rollPop(effective, inno, random, pop, notify)
+	if err != nil {
+		err = &RunError{Phase: PhaseRoll, Generation: pop.Generation + 1, Err: err}
+	}
+	return
+}
+
+// Restores the population from the archiver, repairing its genomes, and scores
+// it and any grid elites. A genome which cannot be repaired is returned as a
+// *ValidationError. A missing archive, or no archiver, begins a new population
+// and returns nil.
+func restore(settings *Settings, arch Archiver) (pop *Population, err error) {
+	if arch == nil {
+		return
+	}
+	pop, err = arch.Restore()
+	if err != nil {
+		if os.IsNotExist(err) {
+			pop, err = nil, nil
+		}
+		return
+	}
+	pop.StopReason = "" // The run continues
+	orgs := pop.Organisms()
+	if pop.Grid != nil {
+		orgs = append(orgs, pop.Grid.Elites()...)
+	}
+	for _, o := range orgs {
+		if vs := Repair(o.Genome, settings); len(vs) > 0 {
+			err = &ValidationError{GenomeID: o.ID, Violations: vs}
+			return
+		}
+	}
+	err = score(settings, pop)
+	if err != nil || pop.Grid == nil {
+		return
+	}
+
+	// Score the grid's elites among themselves so crossover can order them.
+	// They are measured against a copy of the novelty archive so it is not
+	// changed.
+	elites := &Population{Species: []*Species{{Orgs: pop.Grid.Elites()}}}
+	if pop.Novelty != nil {
+		elites.Novelty = &NoveltyArchive{Entries: append([]NoveltyEntry(nil), pop.Novelty.Entries...)}
+	}
+	err = score(settings, elites)
+	return
+}
+
+// Evaluates and scores the population, stopping early if the context is
+// cancelled and the population evaluator supports it
+func evaluate(ctx context.Context, settings *Settings, pop *Population, popEval PopEval, orgEval OrgEval) (err error) {
+	if be, ok := orgEval.(BehaviorEval); ok {
+		orgEval = behaviorEval{be}
+	}
+	if cpe, ok := popEval.(ContextPopEval); ok {
+		err = cpe.EvaluateContext(ctx, pop, orgEval)
+	} else {
+		err = popEval.Evaluate(pop, orgEval)
+	}
+	if err == nil {
+		err = ctx.Err() // Evaluation may have been cut short
+	}
+	if err == nil {
+		err = score(settings, pop)
+	}
+	return
+}
+
+// Ensures every organism in the population is decoded. Decoding stops early
+// if the context is cancelled.
+func decode(ctx context.Context, pop *Population, dcode Decoder) (err error) {
+
+	var w sync.WaitGroup
+	var m sync.Mutex
+	for _, o := range pop.Organisms() {
+		if o.Phenome != nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		w.Add(1)
+		go func(o *Organism) {
+			defer w.Done()
+			p, e := dcode.Decode(o.Genome)
+			m.Lock()
+			defer m.Unlock()
+			if e != nil {
+				if err == nil {
+					err = e
+				}
+				return
+			}
+			o.Phenome = p
+		}(o)
+	}
+	w.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// Returns the generation of the population or 0 if there is none
+func generation(pop *Population) int {
+	if pop == nil {
+		return 0
+	}
+	return pop.Generation
 }
@@ -0,0 +1,260 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat_test
+
+import (
+	"context"
+	"errors"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/archiver"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neat/popeval"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Settings for a small XOR population
+func xorSettings() *neat.Settings {
+	return &neat.Settings{PopulationSize: 50, BiasCount: 1, InputCount: 2, OutputCount: 1,
+		ExcessCoefficient: 1, DisjointCoefficient: 1, WeightCoefficient: 0.4,
+		MutateWeight: 0.8, MutateWeightNew: 0.1, MutateEnabled: 0.05,
+		MutateAddConnection: 0.3, MutateAddNode: 0.1,
+		Crossover: 0.75, InterspeciesMating: 0.001, AgeToStagnation: 15,
+		SurvivalPercent: 0.2, EliteCount: 1, CompatThreshold: 3, Seed: 1}
+}
+
+// Scores each organism by how closely it computes XOR
+type xorEval struct{}
+
+func (e xorEval) Evaluate(org *neat.Organism) (err error) {
+	cases := [][]float64{{0, 0, 0}, {0, 1, 1}, {1, 0, 1}, {1, 1, 0}}
+	sum := float64(0)
+	for _, c := range cases {
+		var out []float64
+		out, err = org.Phenome.Analyze(c[:2])
+		if err != nil {
+			return
+		}
+		sum += math.Abs(out[0] - c[2])
+	}
+	org.Fitness = []float64{math.Pow(4-sum, 2)}
+	return
+}
+
+// Keeps archived populations in memory, optionally failing
+type memArchiver struct {
+	pops []*neat.Population
+	err  error // Returned by Archive once set
+}
+
+func (a *memArchiver) Archive(pop *neat.Population) (err error) {
+	if a.err != nil {
+		return a.err
+	}
+	a.pops = append(a.pops, pop)
+	return
+}
+
+func (a *memArchiver) Restore() (pop *neat.Population, err error) {
+	if len(a.pops) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return a.pops[len(a.pops)-1], nil
+}
+
+// Cancels the run part way through evaluating the given generation
+type cancelEval struct {
+	neat.OrgEval
+	cancel func()
+	after  int // Number of evaluations before cancelling
+	count  int
+	before func() // Called just before cancelling
+}
+
+func (e *cancelEval) Evaluate(org *neat.Organism) (err error) {
+	e.count++
+	if e.count == e.after {
+		if e.before != nil {
+			e.before()
+		}
+		e.cancel()
+	}
+	return e.OrgEval.Evaluate(org)
+}
+
+// Cancels a run during its third generation and checks the error and the
+// archived population
+func TestRunCancel(t *testing.T) {
+	settings := xorSettings()
+	settings.ArchiveFrequency = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	arch := &memArchiver{}
+	eval := &cancelEval{OrgEval: xorEval{}, cancel: cancel, after: 2*settings.PopulationSize + 10}
+
+	last, err := neat.Run(ctx, settings, nil, decoder.NewNEAT(), popeval.NewSerial(), eval, arch, nil)
+	var re *neat.RunError
+	if !errors.As(err, &re) {
+		t.Fatalf("Got error %v, want a *RunError", err)
+	}
+	if re.Phase != neat.PhaseEvaluate || re.Generation != 3 {
+		t.Errorf("Got phase %v in generation %d, want evaluate in generation 3", re.Phase, re.Generation)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Got error %v, want it to wrap context.Canceled", err)
+	}
+
+	// The first generation is archived as usual and the second when cancelled
+	if last == nil || last.Generation != 2 {
+		t.Fatalf("Got last population %v, want generation 2", last)
+	}
+	if len(arch.pops) != 2 || arch.pops[1] != last {
+		t.Fatalf("Got %d archived populations, want generations 1 and 2", len(arch.pops))
+	}
+	if last.Innovations == nil || last.Innovations.NextMarker == 0 {
+		t.Error("Archived population has no innovation history")
+	}
+	for _, o := range last.Organisms() {
+		if len(o.Fitness) == 0 {
+			t.Fatalf("Archived organism %d has not been evaluated", o.ID)
+		}
+	}
+}
+
+// Checks that an error archiving a cancelled run is returned with the
+// cancellation
+func TestRunCancelArchiveError(t *testing.T) {
+	settings := xorSettings()
+	settings.ArchiveFrequency = 10
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	archErr := errors.New("disk full")
+	arch := &memArchiver{}
+	eval := &cancelEval{OrgEval: xorEval{}, cancel: cancel, after: 2*settings.PopulationSize + 10,
+		before: func() { arch.err = archErr }}
+
+	_, err := neat.Run(ctx, settings, nil, decoder.NewNEAT(), popeval.NewSerial(), eval, arch, nil)
+	var re *neat.RunError
+	if !errors.As(err, &re) || re.Phase != neat.PhaseEvaluate {
+		t.Fatalf("Got error %v, want an evaluate *RunError", err)
+	}
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, archErr) {
+		t.Errorf("Got error %v, want both the cancellation and the archive error", err)
+	}
+}
+
+// Runs a population, resumes it from its archive and checks that the resumed
+// run continues the innovation counters and reuses the archived markers
+func TestResumeInnovations(t *testing.T) {
+	settings := xorSettings()
+	settings.InnovationScope = "run"
+	arch := archiver.NewJSON(filepath.Join(t.TempDir(), "pop.json"))
+	first, err := neat.Run(context.Background(), settings, neat.MaxGenerations(5), decoder.NewNEAT(),
+		popeval.NewSerial(), xorEval{}, arch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := first.Innovations
+	known := make(map[[2]int]int)
+	for _, c := range h.Conns {
+		known[[2]int{c.Source, c.Target}] = c.Marker
+	}
+	ids, markers := make(map[int]bool), make(map[int]bool)
+	for _, o := range first.Organisms() {
+		ids[o.ID] = true
+		for m := range o.Conns {
+			markers[m] = true
+		}
+	}
+
+	second, err := neat.Run(context.Background(), settings, neat.MaxGenerations(5), decoder.NewNEAT(),
+		popeval.NewSerial(), xorEval{}, arch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Generation != first.Generation+5 {
+		t.Errorf("Got generation %d after resuming, want %d", second.Generation, first.Generation+5)
+	}
+	for _, o := range second.Organisms() {
+		if !ids[o.ID] && o.ID < h.NextID {
+			t.Errorf("Organism %d reuses an ID handed out before resuming", o.ID)
+		}
+		for _, c := range o.Conns {
+			m, ok := known[[2]int{c.Source, c.Target}]
+			if ok && m != c.Marker {
+				t.Errorf("Connection %d->%d has marker %d, want %d from the archive", c.Source, c.Target, c.Marker, m)
+			}
+			if !ok && !markers[c.Marker] && c.Marker < h.NextMarker {
+				t.Errorf("Connection %d->%d reuses marker %d handed out before resuming", c.Source, c.Target, c.Marker)
+			}
+		}
+	}
+}
+
+// Records the number of species in each generation reported
+type speciesReporter struct {
+	counts []int
+}
+
+func (r *speciesReporter) Report(pop *neat.Population) (err error) {
+	r.counts = append(r.counts, len(pop.Species))
+	return
+}
+
+// Checks that the number of species converges toward the target from
+// thresholds far too low and far too high
+func TestTargetSpeciesCount(t *testing.T) {
+	for _, ct := range []float64{0.5, 10} {
+		settings := xorSettings()
+		settings.PopulationSize = 100
+		settings.CompatThreshold = ct
+		settings.TargetSpeciesCount = 8
+		settings.CompatAdjust = 0.1
+		rep := &speciesReporter{}
+		_, err := neat.Run(context.Background(), settings, neat.MaxGenerations(80), decoder.NewNEAT(),
+			popeval.NewSerial(), xorEval{}, nil, rep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		early, late := deviation(rep.counts[:20], 8), deviation(rep.counts[len(rep.counts)-20:], 8)
+		if late > 2 || late > early/2 {
+			t.Errorf("From threshold %v the species count was off the target by %.2f at first and %.2f at last: %v",
+				ct, early, late, rep.counts)
+		}
+	}
+}
+
+// Returns the mean distance of the counts from the target
+func deviation(counts []int, target int) float64 {
+	sum := float64(0)
+	for _, n := range counts {
+		sum += math.Abs(float64(n - target))
+	}
+	return sum / float64(len(counts))
+}
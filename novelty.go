@@ -0,0 +1,126 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+	"sort"
+)
+
+// NoveltyArchive remembers the behaviours which were novel enough when they
+// first appeared. Novelty is measured against the archive as well as the
+// current population so that search keeps moving away from where it has been.
+type NoveltyArchive struct {
+	Entries []NoveltyEntry
+}
+
+// A behaviour in the novelty archive
+type NoveltyEntry struct {
+	ID       int       // ID of the organism which showed the behaviour
+	Behavior []float64 // The behaviour
+}
+
+// Wraps a behaviour evaluator so that population evaluators record the
+// behaviour with the organism
+type behaviorEval struct {
+	BehaviorEval
+}
+
+func (e behaviorEval) Evaluate(org *Organism) (err error) {
+	org.Behavior, err = e.EvaluateBehavior(org)
+	return
+}
+
+// Sets the novelty of every organism in the population, the mean distance to
+// its Settings.NoveltyK nearest neighbours among the rest of the population
+// and the novelty archive, then adds those whose novelty exceeds
+// Settings.NoveltyThreshold to the archive. Organisms already in the archive
+// are not added again.
+func scoreNovelty(settings *Settings, pop *Population) {
+
+	if pop.Novelty == nil {
+		pop.Novelty = &NoveltyArchive{}
+	}
+	k := settings.NoveltyK
+	if k < 1 {
+		k = 15
+	}
+
+	// Measure the novelty of each organism
+	orgs := pop.Organisms()
+	archived := make(map[int]bool, len(pop.Novelty.Entries))
+	for _, e := range pop.Novelty.Entries {
+		archived[e.ID] = true
+	}
+	dists := make([]float64, 0, len(orgs)+len(pop.Novelty.Entries))
+	for _, o := range orgs {
+		o.Novelty = 0
+		if o.Behavior == nil {
+			continue
+		}
+		dists = dists[:0]
+		for _, n := range orgs {
+			if n != o && n.Behavior != nil {
+				dists = append(dists, behaviorDistance(o.Behavior, n.Behavior))
+			}
+		}
+		for _, e := range pop.Novelty.Entries {
+			if e.ID != o.ID {
+				dists = append(dists, behaviorDistance(o.Behavior, e.Behavior))
+			}
+		}
+		sort.Float64s(dists)
+		n := k
+		if n > len(dists) {
+			n = len(dists)
+		}
+		for _, d := range dists[:n] {
+			o.Novelty += d
+		}
+		if n > 0 {
+			o.Novelty /= float64(n)
+		}
+	}
+
+	// Archive the novel behaviours
+	for _, o := range orgs {
+		if o.Behavior != nil && o.Novelty > settings.NoveltyThreshold && !archived[o.ID] {
+			pop.Novelty.Entries = append(pop.Novelty.Entries,
+				NoveltyEntry{ID: o.ID, Behavior: append([]float64(nil), o.Behavior...)})
+			archived[o.ID] = true
+		}
+	}
+}
+
+// Returns the Euclidean distance between two behaviours
+func behaviorDistance(a, b []float64) float64 {
+	d := float64(0)
+	for i := 0; i < len(a) && i < len(b); i++ {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(d)
+}
@@ -0,0 +1,166 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"math"
+	"sort"
+)
+
+// Scores the organisms as in NSGA-II (Deb et al., 2002). Every organism is
+// ranked by the non-dominated front it falls in, across the whole population,
+// and ties within a front are broken by crowding distance so that spread out
+// solutions are preferred. The score is positive and orders the organisms
+// first by front and then by crowding distance, so species fitness, selection
+// and survival all follow the NSGA-II ordering.
+func scoreNSGA2(orgs OrganismSlice) {
+	fs := fronts(orgs)
+	for r, f := range fs {
+		crowding(f)
+		for _, o := range f {
+			o.Rank = r
+			c := o.Crowding / (1 + o.Crowding) // Scaled into [0, 1]
+			if math.IsInf(o.Crowding, 1) {
+				c = 1
+			}
+			o.Score = float64(len(fs)-r) + 0.5*c
+		}
+	}
+}
+
+// Returns the organisms which no other organism dominates
+func (pop *Population) ParetoFront() OrganismSlice {
+	fs := fronts(pop.Organisms())
+	if len(fs) == 0 {
+		return nil
+	}
+	return fs[0]
+}
+
+// Returns true if a is no worse than b in every objective and better in one
+func dominates(a, b []float64) bool {
+	better := false
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] < b[i] {
+			return false
+		}
+		if a[i] > b[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// Sorts the organisms into non-dominated fronts, the first being the Pareto
+// front. Organisms keep their relative order within a front.
+func fronts(orgs OrganismSlice) (fs []OrganismSlice) {
+
+	// Count how many organisms dominate each one and note those it dominates
+	n := len(orgs)
+	count := make([]int, n)
+	dominated := make([][]int, n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			switch {
+			case dominates(orgs[i].Fitness, orgs[j].Fitness):
+				dominated[i] = append(dominated[i], j)
+				count[j] += 1
+			case dominates(orgs[j].Fitness, orgs[i].Fitness):
+				dominated[j] = append(dominated[j], i)
+				count[i] += 1
+			}
+		}
+	}
+
+	// Peel off the fronts
+	curr := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if count[i] == 0 {
+			curr = append(curr, i)
+		}
+	}
+	for len(curr) > 0 {
+		f := make(OrganismSlice, len(curr))
+		next := make([]int, 0, n)
+		for k, i := range curr {
+			f[k] = orgs[i]
+			for _, j := range dominated[i] {
+				count[j] -= 1
+				if count[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		sort.Ints(next)
+		fs = append(fs, f)
+		curr = next
+	}
+	return
+}
+
+// Sets the crowding distance of each organism in the front. The organisms at
+// the extremes of any objective are infinitely far from the crowd.
+func crowding(front OrganismSlice) {
+	for _, o := range front {
+		o.Crowding = 0
+	}
+	if len(front) == 0 {
+		return
+	}
+	objs := len(front[0].Fitness)
+	for _, o := range front {
+		if len(o.Fitness) < objs {
+			objs = len(o.Fitness)
+		}
+	}
+	sorted := make(OrganismSlice, len(front))
+	copy(sorted, front)
+	for m := 0; m < objs; m++ {
+		sort.Stable(byObjective{sorted, m})
+		lo, hi := sorted[0].Fitness[m], sorted[len(sorted)-1].Fitness[m]
+		sorted[0].Crowding = math.Inf(1)
+		sorted[len(sorted)-1].Crowding = math.Inf(1)
+		if hi == lo {
+			continue
+		}
+		for i := 1; i < len(sorted)-1; i++ {
+			sorted[i].Crowding += (sorted[i+1].Fitness[m] - sorted[i-1].Fitness[m]) / (hi - lo)
+		}
+	}
+}
+
+// Sorts organisms by a single objective
+type byObjective struct {
+	orgs OrganismSlice
+	m    int
+}
+
+func (b byObjective) Len() int      { return len(b.orgs) }
+func (b byObjective) Swap(i, j int) { b.orgs[i], b.orgs[j] = b.orgs[j], b.orgs[i] }
+func (b byObjective) Less(i, j int) bool {
+	return b.orgs[i].Fitness[b.m] < b.orgs[j].Fitness[b.m]
+}
@@ -0,0 +1,96 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+// Observer is notified of the events within each generation of a run. Embed
+// NullObserver to implement only the callbacks of interest. Callbacks are made
+// from the goroutine running the population and should return quickly.
+type Observer interface {
+	GenerationStart(generation int)                    // A new generation is about to be produced
+	Decoded(pop *Population)                           // Every organism has a phenome
+	Evaluated(pop *Population)                         // Every organism has been evaluated
+	SpeciesCreated(pop *Population, s *Species)        // A child founded a new species
+	SpeciesExtinct(pop *Population, s *Species)        // A species has died out or stagnated
+	ChampionImproved(pop *Population, champ *Organism) // The best fitness of the run has improved
+	PhaseSwitched(pop *Population, complexifying bool) // The search changed between complexifying and simplifying
+}
+
+// NullObserver ignores every event
+type NullObserver struct{}
+
+func (NullObserver) GenerationStart(generation int)                    {}
+func (NullObserver) Decoded(pop *Population)                           {}
+func (NullObserver) Evaluated(pop *Population)                         {}
+func (NullObserver) SpeciesCreated(pop *Population, s *Species)        {}
+func (NullObserver) SpeciesExtinct(pop *Population, s *Species)        {}
+func (NullObserver) ChampionImproved(pop *Population, champ *Organism) {}
+func (NullObserver) PhaseSwitched(pop *Population, complexifying bool) {}
+
+// Fans each event out to a list of observers
+type observers []Observer
+
+func (obs observers) generationStart(generation int) {
+	for _, o := range obs {
+		o.GenerationStart(generation)
+	}
+}
+
+func (obs observers) decoded(pop *Population) {
+	for _, o := range obs {
+		o.Decoded(pop)
+	}
+}
+
+func (obs observers) evaluated(pop *Population) {
+	for _, o := range obs {
+		o.Evaluated(pop)
+	}
+}
+
+func (obs observers) speciesCreated(pop *Population, s *Species) {
+	for _, o := range obs {
+		o.SpeciesCreated(pop, s)
+	}
+}
+
+func (obs observers) speciesExtinct(pop *Population, s *Species) {
+	for _, o := range obs {
+		o.SpeciesExtinct(pop, s)
+	}
+}
+
+func (obs observers) championImproved(pop *Population, champ *Organism) {
+	for _, o := range obs {
+		o.ChampionImproved(pop, champ)
+	}
+}
+
+func (obs observers) phaseSwitched(pop *Population, complexifying bool) {
+	for _, o := range obs {
+		o.PhaseSwitched(pop, complexifying)
+	}
+}
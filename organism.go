@@ -27,7 +27,10 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package neat
 
 import (
+	"bytes"
+	"encoding/gob"
 	"github.com/boggo/neural"
+	"io"
 	"math"
 )
 
@@ -35,102 +38,154 @@ type Phenome interface {
 	Analyze(inputs []float64) (outputs []float64, err error)
 }
 
+// BatchPhenome is implemented by phenomes which can analyze many rows of
+// inputs in one call, reusing their buffers from row to row. The outputs
+// hold one row for each row of inputs.
+type BatchPhenome interface {
+	Phenome
+	AnalyzeBatch(inputs [][]float64) (outputs [][]float64, err error)
+}
+
+// Analyzes each row of inputs, in one call if the phenome is a BatchPhenome
+func AnalyzeBatch(p Phenome, inputs [][]float64) (outputs [][]float64, err error) {
+	if bp, ok := p.(BatchPhenome); ok {
+		return bp.AnalyzeBatch(inputs)
+	}
+	outputs = make([][]float64, len(inputs))
+	for i, in := range inputs {
+		outputs[i], err = p.Analyze(in)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// RecurrentPhenome is implemented by phenomes which keep state from one
+// activation to the next. Step advances the network one time step on the
+// inputs, as does Analyze, and Reset clears the state, as at the start of an
+// episode.
+type RecurrentPhenome interface {
+	Phenome
+	Reset()
+	Step(inputs []float64) (outputs []float64, err error)
+}
+
 type Organism struct {
 	*Genome
-	Phenome `json:"-"`
+	Phenome `json:"-" xml:"-"`
+	Age     int // Ticks this organism has lived in real-time mode
+
+	// Behaviour returned by a BehaviorEval
+	Behavior []float64
+
+	// Set from the fitness after each evaluation according to Settings.Scoring
+	Score    float64 `json:"-" xml:"-"` // Value used for selection and survival
+	Rank     int     `json:"-" xml:"-"` // Non-dominated front, 0 being the Pareto front
+	Crowding float64 `json:"-" xml:"-"` // Crowding distance within the front
+	Novelty  float64 `json:"-" xml:"-"` // Novelty of the behaviour
 }
 
-func cloneOrg(source *Organism, id int) (clone *Organism) {
-	clone = &Organism{Genome: cloneGenome(source.Genome, id)}
-	// phenome will be decoded during next iteration
-	return
+// Encodes only the genome, age and behaviour. The phenome is decoded again
+// when needed.
+func (org *Organism) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	e := gob.NewEncoder(&b)
+	err := e.Encode(org.Genome)
+	if err == nil {
+		err = e.Encode(org.Age)
+	}
+	if err == nil {
+		err = e.Encode(org.Behavior)
+	}
+	return b.Bytes(), err
 }
 
-func mutate(settings *Settings, inno *innovation, org *Organism) {
-
-	switch {
-	case random.Next() < settings.MutateAddNode:
-		mutateAddNode(inno, org)
-	case random.Next() < settings.MutateAddConnection:
-		mutateAddConn(settings, inno, org)
-	default:
-		for _, cg := range org.Conns {
-			if random.Next() < settings.MutateWeight {
-				if random.Next() < settings.MutateWeightNew {
-					mutateWeightNew(cg)
-				} else {
-					mutateWeight(cg)
-				}
-			}
-			if random.Next() < settings.MutateEnabled {
-				mutateEnabled(cg)
-			}
+func (org *Organism) GobDecode(data []byte) (err error) {
+	org.Genome = new(Genome)
+	org.Phenome = nil
+	d := gob.NewDecoder(bytes.NewReader(data))
+	err = d.Decode(org.Genome)
+	if err == nil {
+		err = d.Decode(&org.Age)
+	}
+	if err == nil {
+		err = d.Decode(&org.Behavior)
+		if err == io.EOF {
+			err = nil // Archived before behaviours were kept
 		}
 	}
+	return
+}
+
+// Returns a copy of the organism's genome as a new organism with the next ID
+// from the tracker. The clone has no phenome or fitness of its own.
+func Clone(inno *Innovation, source *Organism) (clone *Organism) {
+	clone = &Organism{Genome: cloneGenome(source.Genome, inno.NextID())}
+	// phenome will be decoded during next iteration
+	return
 }
 
-func mutateAddNode(inno *innovation, org *Organism) {
+func mutateAddNode(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
 
 	// Pick a connection to split
-	var old *ConnGene
-	i := random.Int(len(org.Conns))
-	j := 0
-	for _, v := range org.Conns {
-		if i == j {
-			old = v
-			break
-		}
-		j += 1
+	if len(org.Conns) == 0 {
+		return
 	}
+	markers := org.Conns.markers()
+	old := org.Conns[markers[random.Int(len(markers))]]
 
 	// Note the old source and target
 	src := org.Nodes[old.Source]
 	tgt := org.Nodes[old.Target]
 
-	// Create a new node
+	// Create a new node. If this genome has already split the connection the
+	// remembered node is taken so a new one is made.
 	ng := &NodeGene{Type: neural.HIDDEN, X: (src.X + tgt.X) / 2.0, Y: (src.Y + tgt.Y) / 2.0}
-	ng.Marker = inno.blessNodeGene(nodeKey{ng.X, ng.Y})
+	if len(settings.Activations) > 0 {
+		ng.Activation = settings.Activations[0]
+	}
+	ng.Marker = inno.BlessNode(old.Marker)
+	if _, ok := org.Nodes[ng.Marker]; ok {
+		ng.Marker = inno.NextMarker()
+	}
 	org.Nodes[ng.Marker] = ng
 
 	// Create the new connections
 	cg1 := &ConnGene{Source: src.Marker, Target: ng.Marker, Enabled: true, Weight: 1.0}
-	cg1.Marker = inno.blessConnGene(connKey{cg1.Source, cg1.Target})
+	cg1.Marker = inno.BlessConn(cg1.Source, cg1.Target)
 	org.Conns[cg1.Marker] = cg1
 	cg2 := &ConnGene{Source: ng.Marker, Target: tgt.Marker, Enabled: true, Weight: old.Weight}
-	cg2.Marker = inno.blessConnGene(connKey{cg2.Source, cg2.Target})
+	cg2.Marker = inno.BlessConn(cg2.Source, cg2.Target)
 	org.Conns[cg2.Marker] = cg2
 
 	// Disable the old connection
 	old.Enabled = false
 }
 
-func mutateAddConn(settings *Settings, inno *innovation, org *Organism) {
+func mutateAddConn(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
 
-	// Pick 2 nodes to connect
-	var ng1, ng2 *NodeGene
-	a := random.Int(len(org.Nodes))
-	b := random.Int(len(org.Nodes)-settings.BiasCount-settings.InputCount) +
+	// Pick 2 nodes to connect. The bias and input nodes have the lowest markers
+	// so the second node is never one of them.
+	markers := org.Nodes.markers()
+	a := random.Int(len(markers))
+	b := random.Int(len(markers)-settings.BiasCount-settings.InputCount) +
 		settings.BiasCount + settings.InputCount
-	j := 0
-	for _, v := range org.Nodes {
-		if a == j {
-			ng1 = v
+	ng1 := org.Nodes[markers[a]]
+	ng2 := org.Nodes[markers[b]]
+
+	// validate the nodes. Unless recurrent connections are allowed the
+	// connection must lead forward in the order the nodes are activated.
+	if !settings.Recurrent {
+		if ng1.Marker == ng2.Marker {
+			return // No connections to the same node
 		}
-		if b == j {
-			ng2 = v
+		if activatedBefore(ng2, ng1) {
+			ng1, ng2 = ng2, ng1
+		}
+		if ng1.Type == neural.OUTPUT {
+			return
 		}
-		j++
-	}
-
-	// validate the nodes
-	if ng1.Marker == ng2.Marker {
-		return // No connections to the same node
-	}
-	if ng1.Y > ng2.Y {
-		ng1, ng2 = ng2, ng1
-	}
-	if ng1.Type == neural.OUTPUT {
-		return
 	}
 	if ng2.Type == neural.BIAS || ng2.Type == neural.INPUT {
 		return
@@ -150,11 +205,23 @@ func mutateAddConn(settings *Settings, inno *innovation, org *Organism) {
 
 	// Make the new connection
 	cg := &ConnGene{Source: ng1.Marker, Target: ng2.Marker, Enabled: true, Weight: random.Gaussian()}
-	cg.Marker = inno.blessConnGene(connKey{cg.Source, cg.Target})
+	cg.Marker = inno.BlessConn(cg.Source, cg.Target)
 	org.Conns[cg.Marker] = cg
 }
 
-func mutateWeight(cg *ConnGene) {
+// Returns true if node a is activated before node b by the decoders, which
+// order the nodes by Y, then X, then marker
+func activatedBefore(a, b *NodeGene) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	if a.X != b.X {
+		return a.X < b.X
+	}
+	return a.Marker < b.Marker
+}
+
+func mutateWeight(random *RNG, cg *ConnGene) {
 	cg.Weight += random.Gaussian()
 	if cg.Weight > 30.0 {
 		cg.Weight = 30
@@ -164,7 +231,7 @@ func mutateWeight(cg *ConnGene) {
 	}
 }
 
-func mutateWeightNew(cg *ConnGene) {
+func mutateWeightNew(random *RNG, cg *ConnGene) {
 	cg.Weight = random.Gaussian()
 }
 
@@ -172,19 +239,110 @@ func mutateEnabled(cg *ConnGene) {
 	cg.Enabled = true
 }
 
-func crossover(inno *innovation, p1, p2 *Organism) (child *Organism) {
+// Changes the node's activation to another of those allowed
+func mutateFuncType(settings *Settings, random *RNG, ng *NodeGene) {
+	curr := ng.Activation.Normal()
+	others := make([]Activation, 0, len(settings.Activations))
+	for _, a := range settings.Activations {
+		if a.Normal() != curr {
+			others = append(others, a)
+		}
+	}
+	if len(others) > 0 {
+		ng.Activation = others[random.Int(len(others))]
+	}
+}
+
+// Perturbs the weight of each connection with probability MutateWeight, less
+// those whose weights are replaced
+func perturbWeights(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+	rate := settings.MutateWeight * (1 - settings.MutateWeightNew)
+	for _, m := range org.Conns.markers() {
+		if random.Next() < rate {
+			mutateWeight(random, org.Conns[m])
+		}
+	}
+}
+
+// Replaces the weight of each connection with probability MutateWeight scaled
+// by MutateWeightNew
+func replaceWeights(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+	rate := settings.MutateWeight * settings.MutateWeightNew
+	for _, m := range org.Conns.markers() {
+		if random.Next() < rate {
+			mutateWeightNew(random, org.Conns[m])
+		}
+	}
+}
+
+// Re-enables each disabled connection with probability MutateEnabled. Nothing
+// is disabled here; a connection is disabled when a node is added on it.
+func reenableConns(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+	for _, m := range org.Conns.markers() {
+		if random.Next() < settings.MutateEnabled {
+			mutateEnabled(org.Conns[m])
+		}
+	}
+}
+
+// Flips whether a connection chosen at random is enabled. An enabled
+// connection is only disabled if another enabled connection leads into its
+// target, so no node is cut off.
+func toggleEnabled(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+	markers := org.Conns.markers()
+	if len(markers) == 0 {
+		return
+	}
+	cg := org.Conns[markers[random.Int(len(markers))]]
+	if !cg.Enabled {
+		cg.Enabled = true
+		return
+	}
+	for _, m := range markers {
+		c := org.Conns[m]
+		if c != cg && c.Enabled && c.Target == cg.Target {
+			cg.Enabled = false
+			return
+		}
+	}
+}
+
+// Changes the activation of each hidden and output node with probability
+// MutateFuncType when there are several Activations to choose from
+func changeActivations(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
+	if len(settings.Activations) < 2 {
+		return
+	}
+	for _, m := range org.Nodes.markers() {
+		ng := org.Nodes[m]
+		if ng.Type != neural.BIAS && ng.Type != neural.INPUT && random.Next() < settings.MutateFuncType {
+			mutateFuncType(settings, random, ng)
+		}
+	}
+}
+
+// Returns a child of the two parents. Matching genes are taken from either
+// parent at random and the remaining genes from the fitter parent, the one
+// with the higher Score or, when neither has been scored, the higher first
+// fitness value. The child receives the next ID from the tracker.
+func Crossover(inno *Innovation, random *RNG, p1, p2 *Organism) (child *Organism) {
 
 	// Order parents by fitness
-	if p2.Fitness[0] > p1.Fitness[0] {
+	f1, f2 := p1.Score, p2.Score
+	if f1 == 0 && f2 == 0 {
+		f1, f2 = fitness(p1), fitness(p2)
+	}
+	if f2 > f1 {
 		p1, p2 = p2, p1
 	}
 
 	// Create the new child
-	genome := &Genome{ID: inno.nextID(), Nodes: make(map[int]*NodeGene), Conns: make(map[int]*ConnGene)}
+	genome := &Genome{ID: inno.NextID(), Nodes: make(map[int]*NodeGene), Conns: make(map[int]*ConnGene)}
 	child = &Organism{Genome: genome}
 
 	// Crossover the connection genes
-	for _, cg1 := range p1.Conns {
+	for _, m := range p1.Conns.markers() {
+		cg1 := p1.Conns[m]
 		cg2, ok := p2.Conns[cg1.Marker]
 		if ok {
 			if random.Next() < 0.5 {
@@ -197,10 +355,24 @@ func crossover(inno *innovation, p1, p2 *Organism) (child *Organism) {
 		}
 	}
 
+	// Every child keeps the bias, input and output nodes, connected or not.
+	// Output nodes are taken from either parent, as matching hidden nodes are.
+	for _, m := range p1.Nodes.markers() {
+		ng := p1.Nodes[m]
+		if ng.Type == neural.HIDDEN {
+			continue
+		}
+		if ng2, ok := p2.Nodes[m]; ok && ng.Type == neural.OUTPUT && random.Next() >= 0.5 {
+			ng = ng2
+		}
+		child.Nodes[ng.Marker] = cloneNode(ng)
+	}
+
 	// Crossover the node genes
 	var ng1, ng2 *NodeGene
 	var ok bool
-	for _, cg1 := range child.Conns {
+	for _, m := range child.Conns.markers() {
+		cg1 := child.Conns[m]
 		_, ok = child.Nodes[cg1.Source] // look first in child
 		if !ok {
 			ng1, ok = p1.Nodes[cg1.Source] // Grab from parent 1
@@ -241,8 +413,9 @@ func crossover(inno *innovation, p1, p2 *Organism) (child *Organism) {
 	return
 }
 
-// Returns the compatibiliy distance between the two organisms
-func distance(settings *Settings, o1, o2 *Organism) float64 {
+// Returns the compatibiliy distance between the two organisms, as compared
+// with the compatibility threshold when speciating
+func Distance(settings *Settings, o1, o2 *Organism) float64 {
 
 	// Note from http://www.cs.ucf.edu/~kstanley/neat.html
 	// (How should I test my own version of NEAT to make sure it works)
@@ -270,7 +443,8 @@ func distance(settings *Settings, o1, o2 *Organism) float64 {
 
 	// Make the comparison
 	var d, e, m, w float64
-	for _, cg1 := range o1.Conns {
+	for _, mk := range o1.Conns.markers() {
+		cg1 := o1.Conns[mk]
 		cg2, ok := o2.Conns[cg1.Marker]
 		if ok {
 			m += 1 // This is a match
@@ -289,20 +463,30 @@ func distance(settings *Settings, o1, o2 *Organism) float64 {
 		w = w / m
 	}
 
+	// Count the matching nodes whose functions differ
+	var a float64
+	if settings.ActivationCoefficient != 0 {
+		for mk, ng1 := range o1.Nodes {
+			if ng2, ok := o2.Nodes[mk]; ok && ng1.Activation.Normal() != ng2.Activation.Normal() {
+				a += 1
+			}
+		}
+	}
+
 	return settings.ExcessCoefficient*e + settings.DisjointCoefficient*d +
-		settings.WeightCoefficient*w
+		settings.WeightCoefficient*w + settings.ActivationCoefficient*a
 }
 
 type OrganismSlice []*Organism
 
 func (os OrganismSlice) Len() int           { return len(os) }
 func (os OrganismSlice) Swap(i, j int)      { os[i], os[j] = os[j], os[i] }
-func (os OrganismSlice) Less(i, j int) bool { return os[i].Fitness[0] < os[j].Fitness[0] }
+func (os OrganismSlice) Less(i, j int) bool { return os[i].Score < os[j].Score }
 
 func (os OrganismSlice) TotalFitness() float64 {
 	sum := float64(0)
 	for _, o := range os {
-		sum += o.Fitness[0]
+		sum += o.Score
 	}
 	return sum
 }
@@ -315,18 +499,11 @@ func (os OrganismSlice) TotalFitness() float64 {
 //
 // Neurons with only one incoming or one outgoing connection can be replaced with however many connections were on the other side of the neuron, therefore these are candidates for deletion.
 
-func mutateDelNode(settings *Settings, org *Organism) {
+func mutateDelNode(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
 
 	// Pick a node to delete
-	var n *NodeGene
-	i := random.Int(len(org.Nodes))
-	for _, v := range org.Nodes {
-		n = v
-		if i == 0 {
-			break
-		}
-		i -= 1
-	}
+	markers := org.Nodes.markers()
+	n := org.Nodes[markers[random.Int(len(markers))]]
 	if n.Type != neural.HIDDEN {
 		return
 	} // Only remove hidden nodes
@@ -334,7 +511,13 @@ func mutateDelNode(settings *Settings, org *Organism) {
 	// Node the incoming and outgoing connections
 	incoming := make([]*ConnGene, 0, 10)
 	outgoing := make([]*ConnGene, 0, 10)
-	for _, c := range org.Conns {
+	loops := make([]*ConnGene, 0, 1)
+	for _, m := range org.Conns.markers() {
+		c := org.Conns[m]
+		if c.Source == n.Marker && c.Target == n.Marker {
+			loops = append(loops, c) // Neither incoming nor outgoing
+			continue
+		}
 		if c.Source == n.Marker {
 			outgoing = append(outgoing, c)
 		}
@@ -362,7 +545,7 @@ func mutateDelNode(settings *Settings, org *Organism) {
 		// Replace the node in the outgoing connections
 		a := incoming[0]
 		for _, c := range outgoing {
-			c.Source = a.Source
+			rewire(settings, inno, org, c, a.Source, c.Target)
 		}
 
 		// Delete the incoming connection and node
@@ -374,34 +557,53 @@ func mutateDelNode(settings *Settings, org *Organism) {
 		// Replace the node in the incoming connections
 		a := outgoing[0]
 		for _, c := range incoming {
-			c.Target = a.Target
+			rewire(settings, inno, org, c, c.Source, a.Target)
 		}
 
 		// Delete the incoming connection and node
 		delete(org.Conns, a.Marker)
 		delete(org.Nodes, n.Marker)
 	}
+
+	// Connections from the node to itself go with it
+	if _, ok := org.Nodes[n.Marker]; !ok {
+		for _, c := range loops {
+			delete(org.Conns, c.Marker)
+		}
+	}
+}
+
+// Moves the connection to join the source and target nodes. The moved
+// connection is a new structure and so is blessed again. It is dropped if the
+// genome already joins those nodes or if it would join a node to itself when
+// recurrent connections are not allowed.
+func rewire(settings *Settings, inno *Innovation, org *Organism, cg *ConnGene, source, target int) {
+	delete(org.Conns, cg.Marker)
+	if source == target && !settings.Recurrent {
+		return
+	}
+	for _, c := range org.Conns {
+		if c.Source == source && c.Target == target {
+			return
+		}
+	}
+	cg.Source, cg.Target = source, target
+	cg.Marker = inno.BlessConn(source, target)
+	org.Conns[cg.Marker] = cg
 }
 
 // Removes a connection gene
 // From http://sharpneat.sourceforge.net/phasedsearch.html
 // Connection deletion is very simply the deletion of a randomly selected connection, all connections are considered to be available for deletion. When a connection is deleted the neurons that were at each end of the connection are tested to check if they are no longer connected to by other connections, if this is the case then the stranded neuron is also deleted. Note that a more thorough cleanup routine could be invoked at this point that cleans up any dead-end structures that could not possibly be functional, but this can become complex and so we leave NEAT to eliminate such structures naturally.
 //
-func mutateDelConnection(settings *Settings, org *Organism) {
+func mutateDelConnection(settings *Settings, inno *Innovation, random *RNG, org *Organism) {
 
 	// Pick a connection to remove
 	if len(org.Conns) == 0 {
 		return
 	}
-	var c *ConnGene
-	i := random.Int(len(org.Conns))
-	for _, v := range org.Conns {
-		c = v
-		if i == 0 {
-			break
-		}
-		i -= 1
-	}
+	markers := org.Conns.markers()
+	c := org.Conns[markers[random.Int(len(markers))]]
 
 	// Node the nodes connected
 	src := org.Nodes[c.Source]
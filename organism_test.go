@@ -0,0 +1,85 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"github.com/boggo/neural"
+	"testing"
+)
+
+// Returns a parent with a bias, two inputs and an output using the
+// activation, connected by the connections, given as marker, source and
+// target
+func testParent(id int, output Activation, conns ...[3]int) *Organism {
+	g := &Genome{ID: id, Nodes: make(NodeGeneMap), Conns: make(ConnGeneMap)}
+	for m, t := range []neural.NodeType{neural.BIAS, neural.INPUT, neural.INPUT, neural.OUTPUT} {
+		g.Nodes[m+1] = &NodeGene{Marker: m + 1, Type: t}
+	}
+	g.Nodes[4].Activation = output
+	for _, c := range conns {
+		g.Conns[c[0]] = &ConnGene{Marker: c[0], Source: c[1], Target: c[2], Enabled: true, Weight: 1}
+	}
+	return &Organism{Genome: g}
+}
+
+// Checks that children inherit the output node's activation from either parent
+func TestCrossoverOutputActivation(t *testing.T) {
+	inno := NewInnovation(nil)
+	random := NewRNG(1)
+	p1 := testParent(1, "", [3]int{10, 2, 4})
+	p2 := testParent(2, Tanh, [3]int{10, 2, 4})
+	counts := make(map[Activation]int)
+	for i := 0; i < 100; i++ {
+		child := Crossover(inno, random, p1, p2)
+		counts[child.Nodes[4].Activation] += 1
+	}
+	if counts[""] == 0 || counts[Tanh] == 0 {
+		t.Errorf("Got output activations %v, want both parents'", counts)
+	}
+}
+
+// Checks that the disjoint genes come from the fitter parent whichever
+// argument it is, by Score or, unscored, by fitness
+func TestCrossoverFitterParent(t *testing.T) {
+	inno := NewInnovation(nil)
+	random := NewRNG(1)
+	for _, scored := range []bool{false, true} {
+		p1 := testParent(1, "", [3]int{10, 2, 4})
+		p2 := testParent(2, "", [3]int{10, 2, 4}, [3]int{11, 3, 4})
+		p1.Fitness, p2.Fitness = []float64{1}, []float64{2}
+		if scored {
+			p1.Fitness, p2.Fitness = p2.Fitness, p1.Fitness // Score outranks fitness
+			p1.Score, p2.Score = 1, 2
+		}
+		for i := 0; i < 10; i++ {
+			child := Crossover(inno, random, p1, p2)
+			if _, ok := child.Conns[11]; !ok {
+				t.Fatalf("Scored %v: child lacks the fitter second parent's connection", scored)
+			}
+		}
+	}
+}
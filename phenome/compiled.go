@@ -0,0 +1,166 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package phenome
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+)
+
+// Implementation of Phenome which has flattened the network into arrays in
+// topological order. Activating it walks the arrays without allocating, and
+// the outputs returned share a buffer which the next call overwrites, so
+// the phenome is not safe for concurrent use.
+type compiledPhenome struct {
+	inputs  []int // Index of each input node in the order of the inputs
+	bias    []int // Index of each bias node
+	order   []int // Index of each hidden and output node in activation order
+	funcs   []func(float64) float64
+	start   []int // Range of connections into order[k] is start[k] to start[k+1]
+	sources []int // Index of the source of each connection
+	weights []float64
+	outputs []int // Index of each output node
+	values  []float64
+	result  []float64
+}
+
+// Returns a compiled phenome for the nodes and connections, which must not
+// form a cycle. The inputs and outputs follow the order of the nodes.
+func NewCompiled(nodes []GraphNode, conns []GraphConn) (pnome neat.Phenome, err error) {
+
+	p := &compiledPhenome{values: make([]float64, len(nodes))}
+
+	// Gather the connections into each node
+	into := make([][]GraphConn, len(nodes))
+	outOf := make([][]int, len(nodes))
+	for _, c := range conns {
+		into[c.Target] = append(into[c.Target], c)
+		outOf[c.Source] = append(outOf[c.Source], c.Target)
+	}
+
+	// Sort the nodes topologically, keeping to the given order where free to
+	pending := make([]int, len(nodes))
+	ready := make([]int, 0, len(nodes))
+	for i, n := range nodes {
+		pending[i] = len(into[i])
+		switch n.Type {
+		case neural.BIAS:
+			p.bias = append(p.bias, i)
+		case neural.INPUT:
+			p.inputs = append(p.inputs, i)
+		case neural.OUTPUT:
+			p.outputs = append(p.outputs, i)
+		}
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	done := 0
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		done += 1
+		if nodes[i].Type != neural.BIAS && nodes[i].Type != neural.INPUT {
+			p.order = append(p.order, i)
+			p.funcs = append(p.funcs, nodes[i].Activation.Func())
+			p.start = append(p.start, len(p.sources))
+			for _, c := range into[i] {
+				p.sources = append(p.sources, c.Source)
+				p.weights = append(p.weights, c.Weight)
+			}
+		}
+		for _, t := range outOf[i] {
+			pending[t] -= 1
+			if pending[t] == 0 {
+				ready = append(ready, t)
+			}
+		}
+	}
+	if done < len(nodes) {
+		err = errors.New("Network has a cycle and cannot be compiled")
+		return
+	}
+	p.start = append(p.start, len(p.sources))
+	if len(p.outputs) == 0 {
+		err = errors.New("Network has no outputs")
+		return
+	}
+	p.result = make([]float64, len(p.outputs))
+
+	pnome = p
+	return
+}
+
+// Analyzes the inputs and returns the values of the output nodes. The outputs
+// are overwritten by the next call.
+func (p *compiledPhenome) Analyze(inputs []float64) (outputs []float64, err error) {
+
+	if len(inputs) < len(p.inputs) {
+		err = fmt.Errorf("Network needs %d inputs, not %d", len(p.inputs), len(inputs))
+		return
+	}
+	for k, i := range p.inputs {
+		p.values[i] = inputs[k]
+	}
+	for _, i := range p.bias {
+		p.values[i] = 1
+	}
+
+	for k, i := range p.order {
+		sum := float64(0)
+		for c := p.start[k]; c < p.start[k+1]; c++ {
+			sum += p.values[p.sources[c]] * p.weights[c]
+		}
+		p.values[i] = p.funcs[k](sum)
+	}
+
+	for k, i := range p.outputs {
+		p.result[k] = p.values[i]
+	}
+	outputs = p.result
+	return
+}
+
+// Analyzes each row of inputs. The outputs of every row share one buffer,
+// which is not reused by later calls.
+func (p *compiledPhenome) AnalyzeBatch(inputs [][]float64) (outputs [][]float64, err error) {
+	n := len(p.result)
+	buf := make([]float64, n*len(inputs))
+	outputs = make([][]float64, len(inputs))
+	for i, in := range inputs {
+		var out []float64
+		out, err = p.Analyze(in)
+		if err != nil {
+			return
+		}
+		outputs[i] = buf[i*n : (i+1)*n : (i+1)*n]
+		copy(outputs[i], out)
+	}
+	return
+}
@@ -0,0 +1,126 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package phenome
+
+import (
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+)
+
+// A node of a graph phenome
+type GraphNode struct {
+	Type       neural.NodeType // Network node type
+	Activation neat.Activation // Function of a hidden or output node
+}
+
+// A connection of a graph phenome between the nodes at the given indices
+type GraphConn struct {
+	Source, Target int
+	Weight         float64
+}
+
+// Implementation of Phenome which applies each node's own activation function
+type graphPhenome struct {
+	nodes []GraphNode
+	conns []GraphConn
+}
+
+// Returns a phenome for the nodes and connections. The connections must be
+// ordered so that those into a node come together, after every connection into
+// their sources. Each hidden and output node is activated once the connections
+// into it have been summed.
+func NewGraph(nodes []GraphNode, conns []GraphConn) neat.Phenome {
+	return &graphPhenome{nodes, conns}
+}
+
+// Analyzes the inputs and returns the values of the output nodes
+func (p *graphPhenome) Analyze(inputs []float64) (outputs []float64, err error) {
+	return p.analyze(inputs, make([]float64, len(p.nodes)), make([]float64, len(p.nodes)), nil)
+}
+
+// Analyzes each row of inputs. The rows share the working buffers and the
+// outputs of every row share one buffer.
+func (p *graphPhenome) AnalyzeBatch(inputs [][]float64) (outputs [][]float64, err error) {
+	values := make([]float64, len(p.nodes))
+	sums := make([]float64, len(p.nodes))
+	var buf []float64
+	outputs = make([][]float64, len(inputs))
+	for i, in := range inputs {
+		n := len(buf)
+		buf, err = p.analyze(in, values, sums, buf)
+		if err != nil {
+			return
+		}
+		outputs[i] = buf[n:len(buf):len(buf)]
+	}
+	return
+}
+
+// Analyzes the inputs using the working buffers, appending the values of the
+// output nodes to outputs
+func (p *graphPhenome) analyze(inputs, values, sums, outputs []float64) ([]float64, error) {
+
+	// Set the bias and inputs. Nodes without connections into them are
+	// activated on nothing.
+	in := 0
+	for i, n := range p.nodes {
+		switch n.Type {
+		case neural.BIAS:
+			values[i] = 1
+		case neural.INPUT:
+			if in == len(inputs) {
+				return outputs, fmt.Errorf("Network needs more than %d inputs", len(inputs))
+			}
+			values[i] = inputs[in]
+			in += 1
+		default:
+			values[i] = n.Activation.Apply(0)
+		}
+		sums[i] = 0
+	}
+
+	// Sum the connections into each node and activate it
+	for i, c := range p.conns {
+		sums[c.Target] += values[c.Source] * c.Weight
+		if i == len(p.conns)-1 || p.conns[i+1].Target != c.Target {
+			values[c.Target] = p.nodes[c.Target].Activation.Apply(sums[c.Target])
+		}
+	}
+
+	// Collect the outputs
+	n := len(outputs)
+	for i, node := range p.nodes {
+		if node.Type == neural.OUTPUT {
+			outputs = append(outputs, values[i])
+		}
+	}
+	if len(outputs) == n {
+		return outputs, fmt.Errorf("Network produced outputs with zero length")
+	}
+	return outputs, nil
+}
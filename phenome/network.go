@@ -41,10 +41,18 @@ func init() {
 // inputs
 type networkPhenome struct {
 	network *neural.Network
+	batch   neat.BatchPhenome // Analyzes batches in place of the network, if not nil
 }
 
 func NewNetwork(network *neural.Network) neat.Phenome {
-	return &networkPhenome{network}
+	return &networkPhenome{network: network}
+}
+
+// Returns a phenome which analyzes single rows with the network and batches
+// with batch. Batch must compute what the network does from each row alone,
+// so the network must not carry state from one row to the next.
+func NewNetworkBatch(network *neural.Network, batch neat.BatchPhenome) neat.Phenome {
+	return &networkPhenome{network: network, batch: batch}
 }
 
 // Analyzes the inputs and returns the results as a slice of float64. This
@@ -60,3 +68,25 @@ func (p *networkPhenome) Analyze(inputs []float64) (outputs []float64, err error
 	}
 	return
 }
+
+// Analyzes each row of inputs. The outputs of every row share one buffer.
+func (p *networkPhenome) AnalyzeBatch(inputs [][]float64) (outputs [][]float64, err error) {
+	if p.batch != nil {
+		return p.batch.AnalyzeBatch(inputs)
+	}
+	outputs = make([][]float64, len(inputs))
+	var buf []float64
+	for i, in := range inputs {
+		var out []float64
+		out, err = p.Analyze(in)
+		if err != nil {
+			return
+		}
+		if buf == nil {
+			buf = make([]float64, 0, len(out)*len(inputs))
+		}
+		buf = append(buf, out...)
+		outputs[i] = buf[len(buf)-len(out) : len(buf) : len(buf)]
+	}
+	return
+}
@@ -0,0 +1,102 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package phenome
+
+import (
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+)
+
+// Implementation of RecurrentPhenome. On each step the inputs are set and
+// every other node is updated at once from the values left by the previous
+// step, so signals take a step to cross each connection beyond the inputs and
+// cycles carry state from step to step.
+type recurrentPhenome struct {
+	nodes  []GraphNode
+	conns  []GraphConn
+	values []float64 // Values of the nodes after the last step
+	sums   []float64
+}
+
+// Returns a recurrent phenome for the nodes and connections, which may be in
+// any order and may form cycles
+func NewRecurrent(nodes []GraphNode, conns []GraphConn) neat.RecurrentPhenome {
+	return &recurrentPhenome{nodes: nodes, conns: conns,
+		values: make([]float64, len(nodes)), sums: make([]float64, len(nodes))}
+}
+
+// Clears the values of every node
+func (p *recurrentPhenome) Reset() {
+	for i := range p.values {
+		p.values[i] = 0
+	}
+}
+
+// Advances the network one step on the inputs
+func (p *recurrentPhenome) Analyze(inputs []float64) (outputs []float64, err error) {
+	return p.Step(inputs)
+}
+
+// Advances the network one step on the inputs and returns the values of the
+// output nodes
+func (p *recurrentPhenome) Step(inputs []float64) (outputs []float64, err error) {
+
+	// Set the bias and inputs
+	in := 0
+	for i, n := range p.nodes {
+		switch n.Type {
+		case neural.BIAS:
+			p.values[i] = 1
+		case neural.INPUT:
+			if in == len(inputs) {
+				err = fmt.Errorf("Network needs more than %d inputs", len(inputs))
+				return
+			}
+			p.values[i] = inputs[in]
+			in += 1
+		}
+		p.sums[i] = 0
+	}
+
+	// Sum the connections on the previous values then activate every node
+	for _, c := range p.conns {
+		p.sums[c.Target] += p.values[c.Source] * c.Weight
+	}
+	for i, n := range p.nodes {
+		if n.Type != neural.BIAS && n.Type != neural.INPUT {
+			p.values[i] = n.Activation.Apply(p.sums[i])
+			if n.Type == neural.OUTPUT {
+				outputs = append(outputs, p.values[i])
+			}
+		}
+	}
+	if len(outputs) == 0 {
+		err = fmt.Errorf("Network produced outputs with zero length")
+	}
+	return
+}
@@ -27,6 +27,7 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package popeval
 
 import (
+	"context"
 	"github.com/boggo/neat"
 	"sync"
 )
@@ -38,18 +39,39 @@ func NewConcurrent() *concurrentPopEval {
 type concurrentPopEval struct{}
 
 func (p concurrentPopEval) Evaluate(pop *neat.Population, orgEval neat.OrgEval) (err error) {
+	return p.EvaluateContext(context.Background(), pop, orgEval)
+}
+
+// Evaluates each organism in its own goroutine. Organisms not yet started
+// when the context is cancelled are skipped. The first error encountered is
+// returned.
+func (p concurrentPopEval) EvaluateContext(ctx context.Context, pop *neat.Population, orgEval neat.OrgEval) (err error) {
 
 	orgs := pop.Organisms()
 
 	var w sync.WaitGroup
-	w.Add(len(orgs))
+	var m sync.Mutex
 	for _, o := range orgs {
+		if ctx.Err() != nil {
+			break
+		}
+		w.Add(1)
 		go func(o *neat.Organism) {
-			err = orgEval.Evaluate(o)
-			w.Done()
+			defer w.Done()
+			e := orgEval.Evaluate(o)
+			if e != nil {
+				m.Lock()
+				if err == nil {
+					err = e
+				}
+				m.Unlock()
+			}
 		}(o)
 	}
 	w.Wait()
 
+	if err == nil {
+		err = ctx.Err()
+	}
 	return
 }
@@ -27,6 +27,7 @@ SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 package popeval
 
 import (
+	"context"
 	"github.com/boggo/neat"
 )
 
@@ -37,6 +38,12 @@ func NewSerial() *serialPopEval {
 type serialPopEval struct{}
 
 func (p serialPopEval) Evaluate(pop *neat.Population, orgEval neat.OrgEval) (err error) {
+	return p.EvaluateContext(context.Background(), pop, orgEval)
+}
+
+// Evaluates the organisms one at a time, stopping at the first error or when
+// the context is cancelled.
+func (p serialPopEval) EvaluateContext(ctx context.Context, pop *neat.Population, orgEval neat.OrgEval) (err error) {
 
 	// Iterate the species within the population
 	for _, s := range pop.Species {
@@ -44,10 +51,16 @@ func (p serialPopEval) Evaluate(pop *neat.Population, orgEval neat.OrgEval) (err
 		// Iterate the organisms within the species
 		for _, o := range s.Orgs {
 
+			// Stop if the run has been cancelled
+			err = ctx.Err()
+			if err != nil {
+				return
+			}
+
 			// Evaluate the organism
 			err = orgEval.Evaluate(o)
 			if err != nil {
-				// do what exactly?
+				return
 			}
 		}
 	}
@@ -34,6 +34,23 @@ import (
 type Population struct {
 	Generation int          // Current generation
 	Species    SpeciesSlice // The species which make up the population
+	Complexity Complexity   // State of the complexity strategy
+	StopReason string       // Why the run stopped with this population, if it has
+	Island     int          // Number of the island, from 1, when run as one of several
+
+	// Current compatibility threshold when it is adjusted toward
+	// Settings.TargetSpeciesCount. Settings.CompatThreshold is used when this
+	// is 0 or there is no target.
+	CompatThreshold float64
+
+	// State of the innovation tracker when the population was archived
+	Innovations *Innovations
+
+	// Behaviours remembered by novelty search
+	Novelty *NoveltyArchive
+
+	// Elites kept by MAP-Elites
+	Grid *Grid
 }
 
 func (pop Population) String() string {
@@ -41,36 +58,48 @@ func (pop Population) String() string {
 }
 
 // Creates the initial population from the settings by cloning the initial genome
-func initialPopulation(settings *Settings, inno *innovation) (pop *Population, err error) {
+func initialPopulation(settings *Settings, inno *Innovation, random *RNG, notify observers) (pop *Population, err error) {
 
 	// The initial population has only one species
 	pop = &Population{Generation: 1, Species: make([]*Species, 1, 10)}
-	pop.Species[0] = &Species{ID: inno.nextID()}
+	pop.Species[0] = &Species{ID: inno.NextID()}
 	pop.Species[0].Orgs = make([]*Organism, settings.PopulationSize)
 
 	// Fill the species with copies of the initial genome
-	ig, e2 := initialGenome(settings, inno)
+	ig, e2 := NewMinimalGenome(settings, inno)
 	if e2 != nil {
 		err = e2
 		return
 	}
 	for i := 0; i < settings.PopulationSize; i++ {
-		g := cloneGenome(ig, inno.nextID())
-		for _, cg := range g.Conns {
-			cg.Weight = random.Gaussian()
+		g := cloneGenome(ig, inno.NextID())
+		for _, m := range g.Conns.markers() {
+			g.Conns[m].Weight = random.Gaussian()
 		}
 		pop.Species[0].Orgs[i] = &Organism{Genome: g}
 	}
+	notify.speciesCreated(pop, pop.Species[0])
 
 	return
 }
 
 // Rolls a population to the next generation
-func rollPop(settings *Settings, inno *innovation, population *Population) (nextPop *Population, err error) {
+func rollPop(settings *Settings, inno *Innovation, random *RNG, population *Population, notify observers) (nextPop *Population, err error) {
+
+	// Look up the parent selection
+	sel, err := selector(settings)
+	if err != nil {
+		return
+	}
+	err = checkMutationRates(settings)
+	if err != nil {
+		return
+	}
 
 	// Construct the next population
 	currPop := population
-	nextPop = &Population{Generation: currPop.Generation + 1,
+	nextPop = &Population{Generation: currPop.Generation + 1, Complexity: currPop.Complexity,
+		Island: currPop.Island, Novelty: currPop.Novelty, CompatThreshold: currPop.CompatThreshold,
 		Species: make([]*Species, 0, len(currPop.Species))}
 
 	// Update the species fitness in the current population
@@ -80,23 +109,20 @@ func rollPop(settings *Settings, inno *innovation, population *Population) (next
 	for _, s := range currPop.Species {
 		s.calcFitness()
 		for _, o := range s.Orgs {
-			if o.Fitness[0] > bestFit {
+			if bestSpecies == nil || o.Score > bestFit {
 				//bestOrg = o
-				bestFit = o.Fitness[0]
+				bestFit = o.Score
 				bestSpecies = s
 			}
 		}
 	}
 
 	// Allow viable species to continue to live but cull their numbers
-	adjFit := float64(0)
-	popFit := float64(0)
 	var living SpeciesSlice
 	living = make([]*Species, 0, len(currPop.Species))
 	for _, s := range currPop.Species {
 		if s.ID == bestSpecies.ID || s.Age-s.BestFitAge < settings.AgeToStagnation {
 			living = append(living, s)
-			adjFit += s.currFitness
 			sort.Sort(sort.Reverse(s.Orgs))
 			keep := int(settings.SurvivalPercent * float64(len(s.Orgs)))
 			if keep < settings.EliteCount {
@@ -106,20 +132,31 @@ func rollPop(settings *Settings, inno *innovation, population *Population) (next
 				keep = len(s.Orgs)
 			}
 			s.Orgs = s.Orgs[:keep]
-			popFit += s.Orgs.TotalFitness()
 			s.Example = s.Orgs[random.Int(keep)]
+		} else {
+			notify.speciesExtinct(currPop, s)
 		}
 	}
 	//sort.Sort(sort.Reverse(living)) // Reverse sort by best fitness
 	popOrgs := living.Organisms(settings)
 
+	// Share the offspring among the species by fitness, weighed as for selection
+	shares := make([]float64, len(living))
+	for i, s := range living {
+		shares[i] = s.currFitness
+	}
+	adjFit := weigh(shares)
+
 	// Create the next generation
-	inno.reset()
+	err = inno.Advance(settings, nextPop.Generation)
+	if err != nil {
+		return
+	}
 	children := make([]*Organism, 0, settings.PopulationSize) // TODO: Make this a channel for concurrency support
-	for _, currS := range living {
+	for si, currS := range living {
 
 		// Copy the species to the next generation
-		cnt := int(currS.currFitness / adjFit * float64(settings.PopulationSize))
+		cnt := int(shares[si] / adjFit * float64(settings.PopulationSize))
 		nextS := &Species{ID: currS.ID, Orgs: make([]*Organism, 0, cnt), Age: currS.Age + 1,
 			BestFitness: currS.BestFitness, BestFitAge: currS.BestFitAge, Example: currS.Example}
 		nextPop.Species = append(nextPop.Species, nextS)
@@ -131,7 +168,10 @@ func rollPop(settings *Settings, inno *innovation, population *Population) (next
 		}
 
 		// Create the offspring
-		orgFit := currS.Orgs.TotalFitness()
+		var parents OrganismSlice
+		if cnt > 0 {
+			parents = sel.Select(settings, random, currS.Orgs, cnt)
+		}
 		for i := 0; i < cnt; i++ {
 
 			// Allow for innerspecies mating. This is done simply by skipping
@@ -143,86 +183,90 @@ func rollPop(settings *Settings, inno *innovation, population *Population) (next
 			}
 
 			// Select parent 1
-			p1 := tournament(currS.Orgs, orgFit)
+			p1 := parents[i]
 
 			// Mutate only
 			if len(currS.Orgs) == 1 || random.Next() > settings.Crossover {
-				child := cloneOrg(p1, inno.nextID())
-				mutate(settings, inno, child)
+				child := Clone(inno, p1)
+				Mutate(settings, inno, random, child)
+				if err = debugCheck(settings, child); err != nil {
+					return
+				}
 				children = append(children, child)
 			} else {
 
 				// Pick a mate
 				var p2 *Organism
 				if random.Next() < settings.InterspeciesMating {
-					p2 = tournament(popOrgs, popFit)
+					p2 = selectOne(sel, settings, random, popOrgs)
 				} else {
-					p2 = tournament(currS.Orgs, orgFit)
+					p2 = selectOne(sel, settings, random, currS.Orgs)
 				}
 
 				// Crossover and mutate
-				child := crossover(inno, p1, p2)
-				mutate(settings, inno, child)
+				child := Crossover(inno, random, p1, p2)
+				Mutate(settings, inno, random, child)
+				if err = debugCheck(settings, child); err != nil {
+					return
+				}
 				children = append(children, child)
 			}
 		}
 
-		// Ensure we have the right number of children
-		if len(children) > settings.PopulationSize {
-			children = children[:settings.PopulationSize]
-		} else {
-			cnt = settings.PopulationSize - len(children)
-			for c := 0; c < cnt; c++ {
-				p1 := tournament(popOrgs, popFit)
-				p2 := tournament(popOrgs, popFit)
-				child := crossover(inno, p1, p2)
-				mutate(settings, inno, child)
-				children = append(children, child)
+	}
+
+	// Ensure we have the right number of children
+	if len(children) > settings.PopulationSize {
+		children = children[:settings.PopulationSize]
+	} else if cnt := settings.PopulationSize - len(children); cnt > 0 {
+		parents := sel.Select(settings, random, popOrgs, 2*cnt)
+		for c := 0; c < cnt; c++ {
+			child := Crossover(inno, random, parents[2*c], parents[2*c+1])
+			Mutate(settings, inno, random, child)
+			if err = debugCheck(settings, child); err != nil {
+				return
 			}
+			children = append(children, child)
 		}
-
 	}
 
 	// Speciate the children
-	speciate(settings, inno, nextPop, children)
+	speciate(settings, inno, nextPop, children, notify)
 
 	// Prune off species which are empty
 	living = make([]*Species, 0, len(living))
 	for _, s := range nextPop.Species {
 		if len(s.Orgs) > 0 {
 			living = append(living, s)
+		} else {
+			notify.speciesExtinct(nextPop, s)
 		}
 	}
 	nextPop.Species = living
 
+	// Nudge the threshold toward the target number of species for the next
+	// generation
+	adjustThreshold(settings, nextPop, len(nextPop.Species))
+
 	// Replace the current population with the next one
 	return
 
 }
 
-func tournament(orgs []*Organism, totFit float64) (champ *Organism) {
-	tgt := random.Next() * totFit
-	sum := float64(0)
-	for _, o := range orgs {
-		sum += o.Fitness[0]
-		if sum >= tgt {
-			champ = o
-			return
-		}
-	}
-	return // Should be an error to get here
-}
-
-func speciate(settings *Settings, inno *innovation, pop *Population, children OrganismSlice) {
+// Places the children into the species of the population, comparing them
+// under the population's compatibility threshold. Children which match no
+// species found new ones.
+func speciate(settings *Settings, inno *Innovation, pop *Population, children OrganismSlice, notify observers) {
 
 	// Iterate the children
+	ct := compatThreshold(settings, pop)
 	for _, child := range children {
 
 		// Iterate the species
 		found := false
 		for _, s := range pop.Species {
-			d := distance(settings, child, s.Example)
-			if d < settings.CompatThreshold {
+			d := Distance(settings, child, s.Example)
+			if d < ct {
 				s.Orgs = append(s.Orgs, child)
 				found = true
 				break
@@ -231,15 +275,50 @@ func speciate(settings *Settings, inno *innovation, pop *Population, children Or
 
 		// No species found, add a new one
 		if !found {
-			newS := &Species{ID: inno.nextID(), Orgs: make([]*Organism, 0, 10)}
+			newS := &Species{ID: inno.NextID(), Orgs: make([]*Organism, 0, 10)}
 			pop.Species = append(pop.Species, newS)
 
 			newS.Orgs = append(newS.Orgs, child)
 			newS.Example = child
+			notify.speciesCreated(pop, newS)
 		}
 	}
 }
 
+// Returns the compatibility threshold the population is speciated under. The
+// population's own threshold is only used while there is a target number of
+// species to adjust it toward.
+func compatThreshold(settings *Settings, pop *Population) float64 {
+	if settings.TargetSpeciesCount > 0 && pop.CompatThreshold > 0 {
+		return pop.CompatThreshold
+	}
+	return settings.CompatThreshold
+}
+
+// Nudges the population's compatibility threshold by Settings.CompatAdjust,
+// lowering it when there are fewer species than Settings.TargetSpeciesCount
+// and raising it when there are more. The threshold never falls below the
+// adjustment. Does nothing without a target.
+func adjustThreshold(settings *Settings, pop *Population, species int) {
+	if settings.TargetSpeciesCount <= 0 {
+		return
+	}
+	step := settings.CompatAdjust
+	if step <= 0 {
+		step = 0.3
+	}
+	ct := compatThreshold(settings, pop)
+	if species < settings.TargetSpeciesCount {
+		ct -= step
+	} else if species > settings.TargetSpeciesCount {
+		ct += step
+	}
+	if ct < step {
+		ct = step
+	}
+	pop.CompatThreshold = ct
+}
+
 func (pop *Population) Organisms() OrganismSlice {
 	n := 0
 	for _, s := range pop.Species {
@@ -265,8 +344,11 @@ func (pop *Population) MPC() float64 {
 	for _, s := range pop.Species {
 		for _, o := range s.Orgs {
 			tot += len(o.Nodes) + len(o.Conns)
+			cnt += 1
 		}
-		cnt += 1
+	}
+	if cnt == 0 {
+		return 0
 	}
 
 	return float64(tot) / float64(cnt)
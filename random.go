@@ -32,41 +32,40 @@ import (
 	"time"
 )
 
-type rng struct {
+// Source of random numbers for a run. Each run has its own so that runs with
+// the same seed are reproducible and concurrent runs do not share state.
+type RNG struct {
 	*rand.Rand
+	iset bool    // Is a Gaussian deviate waiting in gset?
+	gset float64 // The second of the last pair of Gaussian deviates
 }
 
-var (
-	random rng
-)
-
-func init() {
-	random = rng{rand.New(rand.NewSource(time.Now().UnixNano()))}
+// Creates a random number generator from the seed. A seed of 0 uses the clock.
+func NewRNG(seed int64) *RNG {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &RNG{Rand: rand.New(rand.NewSource(seed))}
 }
 
-func (r *rng) Between(a, b float64) float64 {
+func (r *RNG) Between(a, b float64) float64 {
 	return r.Float64()*(b-a) + a
 }
 
-func (r *rng) Next() float64 {
+func (r *RNG) Next() float64 {
 	return r.Float64()
 }
 
-func (r *rng) Int(n int) int {
+func (r *RNG) Int(n int) int {
 	return r.Intn(n)
 }
 
 // Returns a normally distributed deviate with zero mean and unit variance.
 // From Numerical Recipes in C.
 // TODO: involve the mu and sigma parameters. current use mu=0 and sigma=1
-var (
-	iset bool
-	gset float64
-)
-
-func (r *rng) Gaussian() float64 {
+func (r *RNG) Gaussian() float64 {
 	var fac, rsq, v1, v2 float64
-	if iset == false {
+	if r.iset == false {
 		rsq = 0
 		for rsq >= 1.0 || rsq == 0.0 {
 			v1 = 2.0*r.Next() - 1.0
@@ -74,11 +73,11 @@ func (r *rng) Gaussian() float64 {
 			rsq = v1*v1 + v2*v2
 		}
 		fac = math.Sqrt(-2.0 * math.Log(rsq) / rsq)
-		gset = v1 * fac
-		iset = true
+		r.gset = v1 * fac
+		r.iset = true
 		return v2 * fac
 	} else {
-		iset = false
-		return gset
+		r.iset = false
+		return r.gset
 	}
 }
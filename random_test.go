@@ -0,0 +1,67 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat_test
+
+import (
+	"context"
+	"github.com/boggo/neat"
+	"github.com/boggo/neat/decoder"
+	"github.com/boggo/neat/popeval"
+	"reflect"
+	"testing"
+)
+
+// Runs two populations from the same seed with the concurrent evaluator,
+// adding and deleting structure, and checks that every genome and its fitness
+// match
+func TestSeedReproducible(t *testing.T) {
+	run := func() neat.OrganismSlice {
+		settings := xorSettings()
+		settings.Seed = 7
+		settings.PopulationSize = 150
+		settings.MutateAddNode = 0.3
+		settings.MutateDelNode = 0.3
+		settings.MutateDelConnection = 0.2
+		settings.ComplexityStrategy = "blended"
+		settings.PruneThreshold = 1
+		pop, err := neat.Run(context.Background(), settings, neat.MaxGenerations(50), decoder.NewNEAT(),
+			popeval.NewConcurrent(), xorEval{}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pop.Organisms()
+	}
+	a, b := run(), run()
+	if len(a) != len(b) {
+		t.Fatalf("Got %d and %d organisms", len(a), len(b))
+	}
+	for i := range a {
+		if !reflect.DeepEqual(a[i].Genome, b[i].Genome) {
+			t.Fatalf("Organism %d differs between runs:\n%v\n%v", i, a[i].Genome, b[i].Genome)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"context"
+	"time"
+)
+
+// RealTime evolves a population one organism at a time as in rtNEAT
+// (Stanley, Bryant and Miikkulainen, 2005). Rather than replacing the whole
+// population each generation, the worst organism which has lived long enough
+// to be judged is removed and replaced by the offspring of a species chosen in
+// proportion to its average fitness, while the rest of the population keeps
+// running. The compatibility threshold is nudged after every replacement to
+// keep the number of species near Settings.TargetSpeciesCount.
+//
+// The methods of RealTime are not safe for concurrent use.
+type RealTime struct {
+	Population *Population // The population being evolved
+
+	settings *Settings
+	dcode    Decoder
+	inno     *Innovation
+	random   *RNG
+	notify   observers
+}
+
+// Creates a real-time evolver for the population. If pop is nil a new
+// population is created.
+func NewRealTime(settings *Settings, pop *Population, dcode Decoder, obs ...Observer) (rt *RealTime, err error) {
+
+	rt = &RealTime{Population: pop, settings: settings, dcode: dcode,
+		inno: NewInnovation(pop), random: NewRNG(settings.Seed), notify: observers(obs)}
+	if rt.Population == nil {
+		rt.Population, err = initialPopulation(settings, rt.inno, rt.random, rt.notify)
+		if err != nil {
+			return
+		}
+	}
+	for _, s := range rt.Population.Species {
+		if s.Example == nil && len(s.Orgs) > 0 {
+			s.Example = s.Orgs[0] // The initial population has no examples
+		}
+	}
+	return
+}
+
+// Marks the passing of one tick of time, ageing every organism
+func (rt *RealTime) Tick() {
+	rt.Population.Generation += 1
+	for _, o := range rt.Population.Organisms() {
+		o.Age += 1
+	}
+}
+
+// Replaces the organism with the worst adjusted fitness among those at least
+// Settings.RealTimeMinAge ticks old. The new organism is decoded and placed
+// into a species. If no organism is old enough nothing is replaced and both
+// removed and added are nil.
+func (rt *RealTime) Replace() (removed, added *Organism, err error) {
+
+	pop := rt.Population
+	settings := rt.settings
+	err = score(settings, pop)
+	if err != nil {
+		return
+	}
+
+	// Find the worst mature organism, sharing fitness within its species
+	var worstS *Species
+	var worstI int
+	var worstFit float64
+	for _, s := range pop.Species {
+		for i, o := range s.Orgs {
+			if o.Age < settings.RealTimeMinAge {
+				continue
+			}
+			adj := o.Score / float64(len(s.Orgs))
+			if worstS == nil || adj < worstFit {
+				worstS, worstI, worstFit = s, i, adj
+			}
+		}
+	}
+	if worstS == nil {
+		return
+	}
+
+	// Remove it
+	removed = worstS.Orgs[worstI]
+	rt.remove(worstS, worstI)
+
+	// Choose the parent species in proportion to the average fitness of its
+	// mature members, weighed as for selection
+	avg := make([]float64, len(pop.Species))
+	for i, s := range pop.Species {
+		n := 0
+		for _, o := range s.Orgs {
+			if o.Age >= settings.RealTimeMinAge {
+				avg[i] += o.Score
+				n += 1
+			}
+		}
+		if n > 0 {
+			avg[i] /= float64(n)
+		}
+	}
+	tot := weigh(avg)
+	parentS := pop.Species[spin(avg, rt.random.Next()*tot)]
+
+	// Breed the replacement
+	err = rt.inno.Advance(settings, pop.Generation)
+	if err != nil {
+		return
+	}
+	sel, err := selector(settings)
+	if err != nil {
+		return
+	}
+	err = checkMutationRates(settings)
+	if err != nil {
+		return
+	}
+	p1 := selectOne(sel, settings, rt.random, parentS.Orgs)
+	if len(parentS.Orgs) == 1 || rt.random.Next() > settings.Crossover {
+		added = Clone(rt.inno, p1)
+	} else {
+		var p2 *Organism
+		if rt.random.Next() < settings.InterspeciesMating {
+			p2 = selectOne(sel, settings, rt.random, pop.Organisms())
+		} else {
+			p2 = selectOne(sel, settings, rt.random, parentS.Orgs)
+		}
+		added = Crossover(rt.inno, rt.random, p1, p2)
+	}
+	Mutate(settings, rt.inno, rt.random, added)
+	if err = debugCheck(settings, added); err != nil {
+		return
+	}
+	added.Fitness = []float64{0}
+	added.Phenome, err = rt.dcode.Decode(added.Genome)
+	if err != nil {
+		return
+	}
+
+	// Place the new organism into a species and adjust the compatibility
+	// threshold toward the target number of species. If the threshold changed
+	// the whole population is reassigned, as in rtNEAT.
+	ct := compatThreshold(settings, pop)
+	speciate(settings, rt.inno, pop, []*Organism{added}, rt.notify)
+	adjustThreshold(settings, pop, len(pop.Species))
+	if compatThreshold(settings, pop) == ct {
+		return
+	}
+	orgs := pop.Organisms()
+	for _, s := range pop.Species {
+		s.Orgs = make([]*Organism, 0, len(s.Orgs))
+	}
+	speciate(settings, rt.inno, pop, orgs, rt.notify)
+	living := make([]*Species, 0, len(pop.Species))
+	for _, s := range pop.Species {
+		if len(s.Orgs) > 0 {
+			living = append(living, s)
+		} else {
+			rt.notify.speciesExtinct(pop, s)
+		}
+	}
+	pop.Species = living
+	return
+}
+
+// Removes the species' ith organism from the population, and the species if
+// it was the last member
+func (rt *RealTime) remove(s *Species, i int) {
+	pop := rt.Population
+	o := s.Orgs[i]
+	s.Orgs = append(s.Orgs[:i], s.Orgs[i+1:]...)
+	if s.Example == o && len(s.Orgs) > 0 {
+		s.Example = s.Orgs[0]
+	}
+	if len(s.Orgs) == 0 {
+		living := make([]*Species, 0, len(pop.Species))
+		for _, x := range pop.Species {
+			if x != s {
+				living = append(living, x)
+			}
+		}
+		pop.Species = living
+		rt.notify.speciesExtinct(pop, s)
+	}
+}
+
+// Runs the population in real-time mode until the stop condition is met. Each
+// tick every organism is evaluated once by popEval, and every
+// Settings.RealTimeInterval ticks one organism is replaced. The evaluator is
+// responsible for accumulating an organism's fitness across ticks, for
+// instance by averaging over the ticks of its Age. The stop condition and the
+// archive and report frequencies count ticks as generations, and see the
+// population before that tick's replacement. Errors and cancellation are
+// handled as in Run, a replacement which has not yet been evaluated being left
+// out of the population returned and archived.
+func RunRealTime(ctx context.Context, settings *Settings, stop StopCondition, dcode Decoder, popEval PopEval,
+	orgEval OrgEval, arch Archiver, rep Reporter, obs ...Observer) (last *Population, err error) {
+
+	// Restore the population and create the evolver
+	population, err := restore(settings, arch)
+	if err != nil {
+		err = &RunError{Phase: PhaseRestore, Err: err}
+		return
+	}
+	rt, err := NewRealTime(settings, population, dcode, obs...)
+	if err != nil {
+		err = &RunError{Phase: PhaseInitialize, Err: err}
+		return
+	}
+	population = rt.Population
+	interval := settings.RealTimeInterval
+	if interval < 1 {
+		interval = 1
+	}
+
+	// Archive the population if the run is cut short, without any replacement
+	// which has not been evaluated
+	archived := true
+	var added *Organism
+	defer func() {
+		if err == nil || ctx.Err() == nil || last == nil {
+			return
+		}
+		for _, s := range last.Species {
+			for i, o := range s.Orgs {
+				if o == added {
+					rt.remove(s, i)
+					break
+				}
+			}
+		}
+		if arch != nil && !archived {
+			last.Innovations = rt.inno.History()
+			err = joinArchive(err, last.Generation, arch.Archive(last))
+		}
+	}()
+
+	prog := &Progress{Started: time.Now()}
+	for i := 0; ; i++ {
+
+		// Stop between ticks if asked
+		if e := ctx.Err(); e != nil {
+			err = &RunError{Phase: PhaseEvaluate, Generation: population.Generation, Err: e}
+			return
+		}
+		rt.notify.generationStart(population.Generation + 1)
+		rt.Tick()
+
+		// Ensure every organism is decoded. Only the initial or restored
+		// population should need it.
+		err = decode(ctx, population, dcode)
+		if err != nil {
+			err = &RunError{Phase: PhaseDecode, Generation: population.Generation, Err: err}
+			return
+		}
+		rt.notify.decoded(population)
+
+		// Let every organism run for the tick
+		err = evaluate(ctx, settings, population, popEval, orgEval)
+		if err != nil {
+			err = &RunError{Phase: PhaseEvaluate, Generation: population.Generation, Err: err}
+			return
+		}
+		last = population
+		archived = false
+		added = nil
+		rt.notify.evaluated(population)
+
+		// Check the stop condition
+		done := false
+		if champ := prog.update(population); champ != nil {
+			rt.notify.championImproved(population, champ)
+		}
+		if stop != nil {
+			done, population.StopReason = stop.Stop(population, prog)
+			if !done {
+				population.StopReason = ""
+			}
+		}
+
+		// Archive the population
+		if arch != nil && (done ||
+			(settings.ArchiveFrequency == 0 || i%settings.ArchiveFrequency == 0)) {
+			population.Innovations = rt.inno.History()
+			err = arch.Archive(population)
+			if err != nil {
+				err = &RunError{Phase: PhaseArchive, Generation: population.Generation, Err: err}
+				return
+			}
+			archived = true
+		}
+
+		// Report the population
+		if rep != nil && (done || (settings.ReportFrequency == 0 || i%settings.ReportFrequency == 0)) {
+			err = rep.Report(population)
+			if err != nil {
+				err = &RunError{Phase: PhaseReport, Generation: population.Generation, Err: err}
+				return
+			}
+		}
+
+		if done {
+			return
+		}
+
+		// Replace an organism, which is evaluated in the next tick
+		if population.Generation%interval == 0 {
+			_, added, err = rt.Replace()
+			if err != nil {
+				err = &RunError{Phase: PhaseRoll, Generation: population.Generation, Err: err}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+// Package render draws genomes, as Graphviz DOT or directly as SVG, for
+// inspecting evolved topologies.
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/boggo/neat"
+	"github.com/boggo/neural"
+	"io"
+	"math"
+	"sort"
+)
+
+// Size of the SVG drawing and of its nodes, in pixels
+const (
+	width  = 640
+	height = 480
+	margin = 40
+	radius = 14
+)
+
+// Writes the genome as a Graphviz DOT digraph. The nodes are pinned at their
+// X/Y positions for neato and fdp, with the inputs at the bottom, and ranked
+// bottom to top for dot. Disabled connections are dashed, connections are
+// coloured by the sign and magnitude of their weights, and every gene is
+// labelled with its innovation marker.
+func DOT(w io.Writer, genome *neat.Genome) (err error) {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "digraph genome_%d {\n", genome.ID)
+	fmt.Fprintf(b, "\trankdir=BT;\n\tnode [fontname=\"Helvetica\", fontsize=10];\n")
+	fmt.Fprintf(b, "\tedge [fontname=\"Helvetica\", fontsize=8];\n")
+	for _, ng := range nodes(genome) {
+		fmt.Fprintf(b, "\tn%d [label=\"%s\", shape=%s, pos=\"%.2f,%.2f!\"];\n", ng.Marker,
+			nodeLabel(ng, "\\n"), shape(ng), ng.X*width/72, ng.Y*height/72)
+	}
+	most := maxWeight(genome)
+	for _, cg := range conns(genome) {
+		style := "solid"
+		if !cg.Enabled {
+			style = "dashed"
+		}
+		fmt.Fprintf(b, "\tn%d -> n%d [label=\"%d\", color=\"%s\", penwidth=%.2f, style=%s, tooltip=\"%.4f\"];\n",
+			cg.Source, cg.Target, cg.Marker, color(cg.Weight, most), 1+2*magnitude(cg.Weight, most), style,
+			cg.Weight)
+	}
+	fmt.Fprintf(b, "}\n")
+	return b.Flush()
+}
+
+// Writes the genome as an SVG drawing, placing each node at its X/Y
+// position with the inputs at the bottom. Connections are drawn as in DOT.
+func SVG(w io.Writer, genome *neat.Genome) (err error) {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" "+
+		"font-family=\"Helvetica\" font-size=\"10\">\n", width, height)
+	fmt.Fprintf(b, "<title>Genome %d</title>\n", genome.ID)
+	fmt.Fprintf(b, "<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" "+
+		"markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\"/></marker></defs>\n")
+
+	// Draw the connections beneath the nodes
+	most := maxWeight(genome)
+	for _, cg := range conns(genome) {
+		src, tgt := genome.Nodes[cg.Source], genome.Nodes[cg.Target]
+		if src == nil || tgt == nil {
+			continue // Nothing to draw to
+		}
+		x1, y1 := position(src)
+		x2, y2 := position(tgt)
+		attrs := fmt.Sprintf("stroke=\"%s\" stroke-width=\"%.2f\" fill=\"none\" marker-end=\"url(#arrow)\"",
+			color(cg.Weight, most), 1+2*magnitude(cg.Weight, most))
+		if !cg.Enabled {
+			attrs += " stroke-dasharray=\"4,3\""
+		}
+		var lx, ly float64
+		if src == tgt {
+			fmt.Fprintf(b, "<path d=\"M%.1f,%.1f a%d,%d 0 1,1 %d,0\" %s><title>%.4f</title></path>\n",
+				x1-radius/2, y1-radius+2, radius/2+2, radius/2+2, radius, attrs, cg.Weight)
+			lx, ly = x1, y1-2*radius-4
+		} else {
+			ex, ey := x2, y2
+			if d := math.Hypot(x2-x1, y2-y1); d > 0 {
+				ex, ey = x2-(x2-x1)*radius/d, y2-(y2-y1)*radius/d // Stop at the target's edge
+			}
+			fmt.Fprintf(b, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" %s><title>%.4f</title></line>\n",
+				x1, y1, ex, ey, attrs, cg.Weight)
+			lx, ly = (x1+x2)/2, (y1+y2)/2
+		}
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%.1f\" font-size=\"8\" fill=\"#555555\">%d</text>\n",
+			lx+3, ly-3, cg.Marker)
+	}
+
+	// Draw the nodes
+	for _, ng := range nodes(genome) {
+		x, y := position(ng)
+		fill := "#ffffff"
+		switch ng.Type {
+		case neural.BIAS:
+			fill = "#dddddd"
+		case neural.INPUT:
+			fill = "#ddeeff"
+		case neural.OUTPUT:
+			fill = "#ffeedd"
+		}
+		fmt.Fprintf(b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"%d\" fill=\"%s\" stroke=\"#000000\"><title>%s</title></circle>\n",
+			x, y, radius, fill, nodeLabel(ng, " "))
+		fmt.Fprintf(b, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\" dominant-baseline=\"central\">%d</text>\n",
+			x, y, ng.Marker)
+	}
+	fmt.Fprintf(b, "</svg>\n")
+	return b.Flush()
+}
+
+// Returns the genome's nodes in order of their markers
+func nodes(genome *neat.Genome) []*neat.NodeGene {
+	ns := make([]*neat.NodeGene, 0, len(genome.Nodes))
+	for _, ng := range genome.Nodes {
+		ns = append(ns, ng)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].Marker < ns[j].Marker })
+	return ns
+}
+
+// Returns the genome's connections in order of their markers
+func conns(genome *neat.Genome) []*neat.ConnGene {
+	cs := make([]*neat.ConnGene, 0, len(genome.Conns))
+	for _, cg := range genome.Conns {
+		cs = append(cs, cg)
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Marker < cs[j].Marker })
+	return cs
+}
+
+// Returns the position of the node in the SVG drawing
+func position(ng *neat.NodeGene) (x, y float64) {
+	return margin + ng.X*(width-2*margin), height - margin - ng.Y*(height-2*margin)
+}
+
+// Returns the label of the node, its marker, type and activation function
+func nodeLabel(ng *neat.NodeGene, sep string) string {
+	label := fmt.Sprintf("%d", ng.Marker)
+	switch ng.Type {
+	case neural.BIAS:
+		label += sep + "bias"
+	case neural.INPUT:
+		label += sep + "input"
+	case neural.OUTPUT:
+		label += sep + "output " + string(ng.Activation.Normal())
+	default:
+		label += sep + string(ng.Activation.Normal())
+	}
+	return label
+}
+
+// Returns the DOT shape of the node
+func shape(ng *neat.NodeGene) string {
+	switch ng.Type {
+	case neural.BIAS, neural.INPUT:
+		return "box"
+	case neural.OUTPUT:
+		return "doublecircle"
+	default:
+		return "circle"
+	}
+}
+
+// Returns the largest weight magnitude in the genome, at least 1
+func maxWeight(genome *neat.Genome) float64 {
+	most := float64(1)
+	for _, cg := range genome.Conns {
+		most = math.Max(most, math.Abs(cg.Weight))
+	}
+	return most
+}
+
+// Returns the magnitude of the weight relative to the largest, from 0 to 1
+func magnitude(weight, most float64) float64 {
+	return math.Abs(weight) / most
+}
+
+// Returns the colour of the weight: blue when positive and red when negative,
+// fading to grey as the magnitude falls
+func color(weight, most float64) string {
+	m := magnitude(weight, most)
+	grey := 0xbb * (1 - m)
+	if weight < 0 {
+		return fmt.Sprintf("#%02x%02x%02x", int(grey+0xdd*m), int(grey), int(grey))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(grey), int(grey), int(grey+0xdd*m))
+}
@@ -45,11 +45,19 @@ func (rep *consoleReporter) Report(pop *neat.Population) (err error) {
 
 	// Report the progress
 	fmt.Println("=============================================================================")
-	fmt.Println("Generation", pop.Generation, "finished", time.Now())
+	if pop.Island > 0 {
+		fmt.Println("Island", pop.Island, "generation", pop.Generation, "finished", time.Now())
+	} else {
+		fmt.Println("Generation", pop.Generation, "finished", time.Now())
+	}
 
 	fmt.Println("-----------------------------------------------------------------------------")
 	n := len(pop.Species)
-	fmt.Println(n, "Species")
+	if pop.CompatThreshold > 0 {
+		fmt.Printf("%d Species, compatibility threshold %.4f\n", n, pop.CompatThreshold)
+	} else {
+		fmt.Println(n, "Species")
+	}
 	fmt.Printf("  ID    Count   Fitness   Nodes   Conns    Age    Stagn\n")
 	fmt.Printf("------ ------- --------- ------- ------- ------- -------\n")
 
@@ -108,6 +116,21 @@ func (rep *consoleReporter) Report(pop *neat.Population) (err error) {
 	fmt.Println("Best Fitness: ", bs)
 	fmt.Println("Most Complex: ", ms)
 	fmt.Println("Least Complex:", ls)
+	if pf := pop.ParetoFront(); len(pf) > 0 && len(pf[0].Fitness) > 1 {
+		fmt.Println("Pareto Front: ", len(pf), "Organisms")
+		for _, o := range pf {
+			fmt.Println("              ", o)
+		}
+	}
+	if g := pop.Grid; g != nil {
+		fmt.Printf("Grid:          %d Cells, Coverage %.4f, QD-Score %.4f\n", len(g.Cells), g.Coverage(), g.QDScore())
+		for _, c := range g.Cells {
+			fmt.Println("              ", c.Index, c.Elite)
+		}
+	}
+	if pop.StopReason != "" {
+		fmt.Println("Stopped:      ", pop.StopReason)
+	}
 	fmt.Println("-----------------------------------------------------------------------------")
 
 	// Return the error if any
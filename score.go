@@ -0,0 +1,63 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+)
+
+// Scores every organism in the population according to Settings.Scoring.
+// Selection and survival use the score rather than the raw fitness.
+func score(settings *Settings, pop *Population) (err error) {
+	switch settings.Scoring {
+	case "", "single":
+		for _, o := range pop.Organisms() {
+			o.Score, o.Rank, o.Crowding = 0, 0, 0
+			if len(o.Fitness) > 0 {
+				o.Score = o.Fitness[0]
+			}
+		}
+	case "nsga2":
+		scoreNSGA2(pop.Organisms())
+	case "novelty":
+		scoreNovelty(settings, pop)
+		for _, o := range pop.Organisms() {
+			o.Score = o.Novelty
+		}
+	case "blend":
+		scoreNovelty(settings, pop)
+		for _, o := range pop.Organisms() {
+			o.Score = settings.NoveltyWeight * o.Novelty
+			if len(o.Fitness) > 0 {
+				o.Score += (1 - settings.NoveltyWeight) * o.Fitness[0]
+			}
+		}
+	default:
+		err = fmt.Errorf("Unknown scoring %q", settings.Scoring)
+	}
+	return
+}
@@ -0,0 +1,246 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Selector chooses parents for breeding. Select returns n parents chosen from
+// the organisms by their Score, which may be negative, or nothing if there are
+// no organisms. The organisms passed in must not be reordered.
+type Selector interface {
+	Select(settings *Settings, random *RNG, orgs OrganismSlice, n int) (parents OrganismSlice)
+}
+
+var selectors = map[string]Selector{
+	"":           roulette{},
+	"roulette":   roulette{},
+	"sus":        sus{},
+	"rank":       rank{},
+	"tournament": tournament{},
+	"truncation": truncation{},
+}
+
+// Registers a selector so it may be chosen by name through Settings.Selection.
+// Registration should happen during program initialization and replaces any
+// selector of the same name.
+func RegisterSelector(name string, sel Selector) {
+	selectors[name] = sel
+}
+
+// Returns the selector named in the settings
+func selector(settings *Settings) (sel Selector, err error) {
+	sel, ok := selectors[settings.Selection]
+	if !ok {
+		err = fmt.Errorf("Unknown selection %q", settings.Selection)
+	}
+	return
+}
+
+// Returns a single parent chosen by the selector
+func selectOne(sel Selector, settings *Settings, random *RNG, orgs OrganismSlice) *Organism {
+	if p := sel.Select(settings, random, orgs, 1); len(p) > 0 {
+		return p[0]
+	}
+	return nil
+}
+
+// Turns the values into weights for fitness proportionate choice, in place,
+// and returns their total. When any value is negative the values are shifted
+// so the lowest is zero. If every weight is then zero they are made equal.
+func weigh(w []float64) (total float64) {
+	least := math.Inf(1)
+	for _, x := range w {
+		least = math.Min(least, x)
+	}
+	for i := range w {
+		if least < 0 {
+			w[i] -= least
+		}
+		total += w[i]
+	}
+	if total <= 0 {
+		for i := range w {
+			w[i] = 1
+		}
+		total = float64(len(w))
+	}
+	return
+}
+
+// Returns the weight of each organism for fitness proportionate selection
+func weights(orgs OrganismSlice) (w []float64, total float64) {
+	w = make([]float64, len(orgs))
+	for i, o := range orgs {
+		w[i] = o.Score
+	}
+	total = weigh(w)
+	return
+}
+
+// Returns the index of the weight whose span holds the target. Rounding which
+// leaves the target past the end picks the last weighted organism.
+func spin(w []float64, tgt float64) int {
+	last := 0
+	sum := float64(0)
+	for i, x := range w {
+		if x <= 0 {
+			continue
+		}
+		sum += x
+		last = i
+		if tgt < sum {
+			return i
+		}
+	}
+	return last
+}
+
+// Fitness proportionate selection, spinning the wheel once for each parent
+type roulette struct{}
+
+func (sel roulette) Select(settings *Settings, random *RNG, orgs OrganismSlice, n int) (parents OrganismSlice) {
+	if len(orgs) == 0 {
+		return
+	}
+	w, total := weights(orgs)
+	parents = make(OrganismSlice, n)
+	for i := range parents {
+		parents[i] = orgs[spin(w, random.Next()*total)]
+	}
+	return
+}
+
+// Stochastic universal sampling. The wheel is spun once with n evenly spaced
+// pointers, so each organism is chosen close to its expected number of times.
+// The parents are returned shuffled.
+type sus struct{}
+
+func (sel sus) Select(settings *Settings, random *RNG, orgs OrganismSlice, n int) (parents OrganismSlice) {
+	if len(orgs) == 0 || n == 0 {
+		return
+	}
+	w, total := weights(orgs)
+	step := total / float64(n)
+	start := random.Next() * step
+	parents = make(OrganismSlice, n)
+	for i := range parents {
+		parents[i] = orgs[spin(w, start+float64(i)*step)]
+	}
+	random.Shuffle(n, func(i, j int) { parents[i], parents[j] = parents[j], parents[i] })
+	return
+}
+
+// Rank based selection. Organisms are weighed by their rank from 1 for the
+// lowest score to n for the highest, so only the order of the scores matters.
+// Organisms with the same score share the mean of their ranks.
+type rank struct{}
+
+func (sel rank) Select(settings *Settings, random *RNG, orgs OrganismSlice, n int) (parents OrganismSlice) {
+	if len(orgs) == 0 {
+		return
+	}
+	sorted := append(OrganismSlice(nil), orgs...)
+	sort.Stable(sorted)
+	w := make([]float64, len(sorted))
+	for i := 0; i < len(w); {
+		j := i + 1
+		for j < len(w) && sorted[j].Score == sorted[i].Score {
+			j++
+		}
+		for k := i; k < j; k++ {
+			w[k] = float64(i+j+1) / 2 // Mean of the ranks i+1 to j
+		}
+		i = j
+	}
+	total := float64(len(w)*(len(w)+1)) / 2
+	parents = make(OrganismSlice, n)
+	for i := range parents {
+		parents[i] = sorted[spin(w, random.Next()*total)]
+	}
+	return
+}
+
+// Tournament selection. Each parent is the highest scoring of
+// Settings.TournamentSize organisms drawn at random.
+type tournament struct{}
+
+func (sel tournament) Select(settings *Settings, random *RNG, orgs OrganismSlice, n int) (parents OrganismSlice) {
+	if len(orgs) == 0 {
+		return
+	}
+	k := settings.TournamentSize
+	if k < 1 {
+		k = 2
+	}
+	parents = make(OrganismSlice, n)
+	for i := range parents {
+		champ := orgs[random.Int(len(orgs))]
+		for j := 1; j < k; j++ {
+			if o := orgs[random.Int(len(orgs))]; o.Score > champ.Score {
+				champ = o
+			}
+		}
+		parents[i] = champ
+	}
+	return
+}
+
+// Truncation selection. Parents are drawn evenly from the highest scoring
+// Settings.TruncationPercent of the organisms, along with any which score the
+// same as the lowest of them.
+type truncation struct{}
+
+func (sel truncation) Select(settings *Settings, random *RNG, orgs OrganismSlice, n int) (parents OrganismSlice) {
+	if len(orgs) == 0 {
+		return
+	}
+	pct := settings.TruncationPercent
+	if pct <= 0 {
+		pct = 0.5
+	}
+	sorted := append(OrganismSlice(nil), orgs...)
+	sort.Stable(sort.Reverse(sorted))
+	keep := int(math.Ceil(pct * float64(len(sorted))))
+	if keep < 1 {
+		keep = 1
+	}
+	if keep > len(sorted) {
+		keep = len(sorted)
+	}
+	for keep < len(sorted) && sorted[keep].Score == sorted[keep-1].Score {
+		keep++
+	}
+	parents = make(OrganismSlice, n)
+	for i := range parents {
+		parents[i] = sorted[random.Int(keep)]
+	}
+	return
+}
@@ -45,16 +45,50 @@ type Settings struct {
 	DisjointCoefficient float64
 	WeightCoefficient   float64
 
-	// Probabilities for mutation
-	MutateWeight        float64
-	MutateWeightNew     float64
-	MutateEnabled       float64
+	// Probabilities for mutation. The weight, enabled and activation rates
+	// apply to each gene, the others to each child.
+	MutateWeight        float64 // Per connection
+	MutateWeightNew     float64 // Share of the mutated weights which are replaced
+	MutateEnabled       float64 // Per connection, to re-enable it
 	MutateAddConnection float64
 	MutateAddNode       float64
-	MutateFuncType      float64
+	MutateFuncType      float64 // Per hidden or output node, when there are several Activations
 	MutateDelNode       float64 // Pruning phase
 	MutateDelConnection float64 // Pruning phase
 	PruneThreshold      float64 // Pruning phase threshold
+	PruneFloor          int     // Generations without a drop in complexity before pruning ends
+	Recurrent           bool    // Allow recurrent and self connections
+
+	// Rates per child of mutation operators registered by name, overriding
+	// the fields above. Operators working gene by gene are applied to every
+	// child unless given a rate here. Naming an operator which is not
+	// registered is an error.
+	MutationRates []MutationRate
+
+	// Allow a child several mutations, as described for Mutate
+	MultipleMutations bool
+
+	// Functions hidden and output nodes may use. New nodes use the first. Empty
+	// leaves every node sigmoid.
+	Activations []Activation
+
+	// Coefficient for nodes whose activation functions differ in distance
+	ActivationCoefficient float64
+
+	// Name of the complexity strategy: complexify, phased (default) or blended
+	ComplexityStrategy string
+
+	// How organisms are scored for selection and survival: single (default),
+	// which uses the first fitness value, nsga2, which ranks organisms by
+	// non-dominated front and crowding distance over every fitness value,
+	// novelty, which uses the novelty of the organism's behaviour alone, or
+	// blend, which weighs novelty against the first fitness value
+	Scoring string
+
+	// Novelty search settings
+	NoveltyK         int     // Number of nearest neighbours measured. 0 = 15
+	NoveltyThreshold float64 // Novelty needed to enter the novelty archive
+	NoveltyWeight    float64 // Weight of novelty in the blend, fitness having the remainder
 
 	// Crossover and breeding probabilities
 	Crossover          float64
@@ -63,8 +97,36 @@ type Settings struct {
 	SurvivalPercent    float64 // Percent of a species to survive for mating
 	EliteCount         int     // Number within a species to survive into the next generation
 	CompatThreshold    float64 // Compatiblity threshold for adding a genome to a species
+	TargetSpeciesCount int     // Number of species to aim for. 0 = keep the threshold fixed
+	CompatAdjust       float64 // Step used to adjust the compatibility threshold. 0 = 0.3
+
+	// How parents are selected: roulette (default), sus, rank, tournament or
+	// truncation
+	Selection         string
+	TournamentSize    int     // Organisms drawn for each tournament. 0 = 2
+	TruncationPercent float64 // Share of the best organisms truncation draws from. 0 = 0.5
+
+	// How long innovations are remembered so the same structure receives the
+	// same marker: generation (default), window or run
+	InnovationScope  string
+	InnovationWindow int // Number of generations remembered by the window scope
+
+	// Real-time (rtNEAT) settings
+	RealTimeInterval int // Ticks between replacements
+	RealTimeMinAge   int // Ticks an organism must live before it may be replaced
+
+	// Island model settings
+	MigrationInterval int // Generations between migrations. 0 = never migrate
+	MigrationCount    int // Number of an island's best organisms sent to each destination
+
+	// MAP-Elites settings, one value for each behaviour dimension
+	GridBins []int     // Number of bins
+	GridMin  []float64 // Lower bound
+	GridMax  []float64 // Upper bound
 
 	// Runtime settings
-	ArchiveFrequency int // Frequency to archive the population. 0 = archive every iteration
-	ReportFrequency  int // Frequency to report on the population. 0 = report every iteration
+	Debug            bool  // Validate every child after mutation and crossover
+	ArchiveFrequency int   // Frequency to archive the population. 0 = archive every iteration
+	ReportFrequency  int   // Frequency to report on the population. 0 = report every iteration
+	Seed             int64 // Seed for the random number generator. 0 = seed from the clock
 }
@@ -48,12 +48,12 @@ func (s Species) String() string {
 func (s *Species) calcFitness() {
 	sum := float64(0)
 	for _, o := range s.Orgs {
-		sum += o.Fitness[0]
+		sum += o.Score
 	}
 	sum /= float64(len(s.Orgs))
 	s.currFitness = sum
 
-	if sum > s.BestFitness {
+	if sum > s.BestFitness || s.Age == 0 {
 		s.BestFitness = sum
 		s.BestFitAge = s.Age
 	}
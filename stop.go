@@ -0,0 +1,173 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Progress summarises the run so far for the stop conditions
+type Progress struct {
+	Started     time.Time // Time the run began
+	Generations int       // Number of generations evaluated during this run
+	Evaluations int       // Number of organisms evaluated during this run
+	Champion    float64   // Best fitness seen during this run
+	Stagnation  int       // Generations since the champion last improved
+	scored      bool      // Has a champion been seen?
+}
+
+// Updates the progress with a newly evaluated population, returning the new
+// champion if the best fitness improved
+func (p *Progress) update(pop *Population) (champ *Organism) {
+	p.Generations += 1
+	p.Stagnation += 1
+	for _, o := range pop.Organisms() {
+		p.Evaluations += 1
+		if len(o.Fitness) > 0 && (!p.scored || o.Fitness[0] > p.Champion) {
+			p.Champion = o.Fitness[0]
+			p.Stagnation = 0
+			p.scored = true
+			champ = o
+		}
+	}
+	return
+}
+
+// StopCondition decides when a run is finished. It is checked after each
+// generation is evaluated and returns a human readable reason when the run
+// should stop.
+type StopCondition interface {
+	Stop(pop *Population, prog *Progress) (stop bool, reason string)
+}
+
+type maxGenerations struct {
+	n int
+}
+
+// Stops the run once n generations have been evaluated. As stop conditions
+// are checked after each generation, a run always evaluates at least one.
+func MaxGenerations(n int) StopCondition {
+	return &maxGenerations{n}
+}
+
+func (c *maxGenerations) Stop(pop *Population, prog *Progress) (bool, string) {
+	return prog.Generations >= c.n, fmt.Sprintf("%d generations completed", prog.Generations)
+}
+
+type fitnessTarget struct {
+	target float64
+}
+
+// Stops the run once any organism reaches the target fitness
+func FitnessTarget(target float64) StopCondition {
+	return &fitnessTarget{target}
+}
+
+func (c *fitnessTarget) Stop(pop *Population, prog *Progress) (bool, string) {
+	return prog.Champion >= c.target, fmt.Sprintf("fitness target %v reached with %v", c.target, prog.Champion)
+}
+
+type stagnation struct {
+	n int
+}
+
+// Stops the run once the champion has not improved for n generations
+func Stagnation(n int) StopCondition {
+	return &stagnation{n}
+}
+
+func (c *stagnation) Stop(pop *Population, prog *Progress) (bool, string) {
+	return prog.Stagnation >= c.n, fmt.Sprintf("no improvement for %d generations", prog.Stagnation)
+}
+
+type timeLimit struct {
+	d time.Duration
+}
+
+// Stops the run once the wall-clock budget has been spent
+func TimeLimit(d time.Duration) StopCondition {
+	return &timeLimit{d}
+}
+
+func (c *timeLimit) Stop(pop *Population, prog *Progress) (bool, string) {
+	return time.Since(prog.Started) >= c.d, fmt.Sprintf("time limit %v reached", c.d)
+}
+
+type evaluationLimit struct {
+	n int
+}
+
+// Stops the run once n organisms have been evaluated
+func EvaluationLimit(n int) StopCondition {
+	return &evaluationLimit{n}
+}
+
+func (c *evaluationLimit) Stop(pop *Population, prog *Progress) (bool, string) {
+	return prog.Evaluations >= c.n, fmt.Sprintf("%d evaluations completed", prog.Evaluations)
+}
+
+type allOf struct {
+	conds []StopCondition
+}
+
+// Stops the run only when every condition is met
+func And(conds ...StopCondition) StopCondition {
+	return &allOf{conds}
+}
+
+func (c *allOf) Stop(pop *Population, prog *Progress) (bool, string) {
+	reasons := make([]string, 0, len(c.conds))
+	for _, sc := range c.conds {
+		stop, reason := sc.Stop(pop, prog)
+		if !stop {
+			return false, ""
+		}
+		reasons = append(reasons, reason)
+	}
+	return len(reasons) > 0, strings.Join(reasons, " and ")
+}
+
+type anyOf struct {
+	conds []StopCondition
+}
+
+// Stops the run when any of the conditions is met
+func Or(conds ...StopCondition) StopCondition {
+	return &anyOf{conds}
+}
+
+func (c *anyOf) Stop(pop *Population, prog *Progress) (bool, string) {
+	reasons := make([]string, 0, len(c.conds))
+	for _, sc := range c.conds {
+		if stop, reason := sc.Stop(pop, prog); stop {
+			reasons = append(reasons, reason)
+		}
+	}
+	return len(reasons) > 0, strings.Join(reasons, "; ")
+}
@@ -0,0 +1,227 @@
+/*  Copyright (c) 2013, Brian Hummer (brian@boggo.net)
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+    * Redistributions of source code must retain the above copyright
+      notice, this list of conditions and the following disclaimer.
+    * Redistributions in binary form must reproduce the above copyright
+      notice, this list of conditions and the following disclaimer in the
+      documentation and/or other materials provided with the distribution.
+    * Neither the name of the boggo.net nor the
+      names of its contributors may be used to endorse or promote products
+      derived from this software without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL BRIAN HUMMER BE LIABLE FOR ANY
+DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES
+(INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND
+ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package neat
+
+import (
+	"fmt"
+	"github.com/boggo/neural"
+	"strings"
+)
+
+// Kind of problem found in a genome
+type ViolationKind int
+
+const (
+	NilGene           ViolationKind = iota // A gene is missing from its map
+	MarkerMismatch                         // A gene is keyed by a marker other than its own
+	MissingNode                            // A connection's source or target does not exist
+	InvalidTarget                          // A connection leads into a bias or input node
+	DuplicateConn                          // Two connections join the same source and target
+	RecurrentConn                          // A connection leads backward or to itself without Settings.Recurrent
+	UnknownActivation                      // A node has an unknown activation function
+	WrongNodeCount                         // The bias, input or output nodes do not match the settings
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case NilGene:
+		return "nil gene"
+	case MarkerMismatch:
+		return "marker mismatch"
+	case MissingNode:
+		return "missing node"
+	case InvalidTarget:
+		return "invalid target"
+	case DuplicateConn:
+		return "duplicate connection"
+	case RecurrentConn:
+		return "recurrent connection"
+	case UnknownActivation:
+		return "unknown activation"
+	case WrongNodeCount:
+		return "wrong node count"
+	default:
+		return fmt.Sprintf("violation %d", int(k))
+	}
+}
+
+// A problem found in a genome
+type Violation struct {
+	Kind   ViolationKind
+	Marker int    // Key of the gene at fault. 0 for the whole genome
+	Detail string // Description of the problem
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v at %d: %s", v.Kind, v.Marker, v.Detail)
+}
+
+// ValidationError reports the violations found in a genome
+type ValidationError struct {
+	GenomeID   int
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return fmt.Sprintf("Genome %d is invalid: %s", e.GenomeID, strings.Join(s, "; "))
+}
+
+// Checks that the genome is well formed for the settings, returning every
+// violation found in order of the genes' markers
+func Validate(genome *Genome, settings *Settings) (violations []Violation) {
+	return check(genome, settings, false)
+}
+
+// Repairs what it can of the genome's violations by rekeying genes, removing
+// connections which are broken, duplicated, or recurrent when that is not
+// allowed, and resetting unknown activations to the default. The violations
+// which could not be repaired are returned.
+func Repair(genome *Genome, settings *Settings) (remaining []Violation) {
+	return check(genome, settings, true)
+}
+
+// Returns an error listing the genome's violations if Settings.Debug is set
+func debugCheck(settings *Settings, org *Organism) (err error) {
+	if !settings.Debug {
+		return
+	}
+	if vs := Validate(org.Genome, settings); len(vs) > 0 {
+		err = &ValidationError{GenomeID: org.ID, Violations: vs}
+	}
+	return
+}
+
+// Validates the genome, repairing it if asked. Returns the violations found,
+// or those which remain after repair.
+func check(genome *Genome, settings *Settings, repair bool) (violations []Violation) {
+
+	add := func(k ViolationKind, marker int, format string, args ...interface{}) {
+		violations = append(violations, Violation{Kind: k, Marker: marker, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// Check the genes are keyed by their markers
+	for _, k := range genome.Nodes.markers() {
+		ng := genome.Nodes[k]
+		switch {
+		case ng == nil && repair:
+			delete(genome.Nodes, k)
+		case ng == nil:
+			add(NilGene, k, "node gene is nil")
+		case ng.Marker != k:
+			if _, taken := genome.Nodes[ng.Marker]; repair && !taken {
+				delete(genome.Nodes, k)
+				genome.Nodes[ng.Marker] = ng
+			} else {
+				add(MarkerMismatch, k, "node gene has marker %d", ng.Marker)
+			}
+		}
+	}
+	for _, k := range genome.Conns.markers() {
+		cg := genome.Conns[k]
+		switch {
+		case cg == nil && repair:
+			delete(genome.Conns, k)
+		case cg == nil:
+			add(NilGene, k, "connection gene is nil")
+		case cg.Marker != k:
+			if _, taken := genome.Conns[cg.Marker]; repair && !taken {
+				delete(genome.Conns, k)
+				genome.Conns[cg.Marker] = cg
+			} else {
+				add(MarkerMismatch, k, "connection gene has marker %d", cg.Marker)
+			}
+		}
+	}
+
+	// Check the node genes
+	counts := make(map[neural.NodeType]int)
+	for _, k := range genome.Nodes.markers() {
+		ng := genome.Nodes[k]
+		if ng == nil {
+			continue
+		}
+		if !ng.Activation.Known() {
+			if repair {
+				ng.Activation = ""
+			} else {
+				add(UnknownActivation, k, "node uses %q", ng.Activation)
+			}
+		}
+		counts[ng.Type] += 1
+	}
+	for _, c := range []struct {
+		t    neural.NodeType
+		name string
+		want int
+	}{{neural.BIAS, "bias", settings.BiasCount}, {neural.INPUT, "input", settings.InputCount},
+		{neural.OUTPUT, "output", settings.OutputCount}} {
+		if counts[c.t] != c.want {
+			add(WrongNodeCount, 0, "%d %s nodes, not %d", counts[c.t], c.name, c.want)
+		}
+	}
+
+	// Check the connection genes
+	pairs := make(map[connKey]*ConnGene)
+	for _, k := range genome.Conns.markers() {
+		cg := genome.Conns[k]
+		if cg == nil {
+			continue
+		}
+
+		// Connections which must go
+		src, tgt := genome.Nodes[cg.Source], genome.Nodes[cg.Target]
+		var kind ViolationKind
+		var detail string
+		key := connKey{cg.Source, cg.Target}
+		switch {
+		case src == nil || tgt == nil:
+			kind, detail = MissingNode, fmt.Sprintf("connection joins %d to %d", cg.Source, cg.Target)
+		case tgt.Type == neural.BIAS || tgt.Type == neural.INPUT:
+			kind, detail = InvalidTarget, fmt.Sprintf("connection leads into node %d", cg.Target)
+		case pairs[key] != nil:
+			kind, detail = DuplicateConn, fmt.Sprintf("connection duplicates %d", pairs[key].Marker)
+		case !settings.Recurrent && (src == tgt || src.Y > tgt.Y || src.Type == neural.OUTPUT):
+			kind, detail = RecurrentConn, fmt.Sprintf("connection leads back from %d to %d", cg.Source, cg.Target)
+		default:
+			pairs[key] = cg
+			continue
+		}
+		if repair {
+			if kind == DuplicateConn && cg.Enabled {
+				pairs[key].Enabled = true // Keep the connection working
+			}
+			delete(genome.Conns, k)
+		} else {
+			add(kind, k, "%s", detail)
+		}
+	}
+	return
+}